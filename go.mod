@@ -10,6 +10,7 @@ require (
 	github.com/Financial-Times/service-status-go v0.0.0-20160323111542-3f5199736a3d
 	github.com/Financial-Times/transactionid-utils-go v0.2.0
 	github.com/Financial-Times/uuid-utils-go v0.0.0-20180307110105-a9db2d975242
+	github.com/IBM/sarama v1.40.1
 	github.com/gorilla/handlers v1.3.0
 	github.com/gorilla/mux v1.8.0
 	github.com/jawher/mow.cli v1.0.4-0.20171111121841-3ff64ca21987
@@ -19,7 +20,6 @@ require (
 )
 
 require (
-	github.com/IBM/sarama v1.40.1 // indirect
 	github.com/aws/aws-sdk-go-v2 v1.17.8 // indirect
 	github.com/aws/aws-sdk-go-v2/config v1.18.11 // indirect
 	github.com/aws/aws-sdk-go-v2/credentials v1.13.11 // indirect
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -73,6 +75,120 @@ func main() {
 		Desc:   "Address used to connect to Kafka",
 		EnvVar: "KAFKA_ADDR",
 	})
+	deadLetterTopic := app.String(cli.StringOpt{
+		Name:   "deadLetterTopic",
+		Value:  "",
+		Desc:   "Kafka topic which messages that fail transformation or writing are published to. Disabled when empty",
+		EnvVar: "KAFKA_DEAD_LETTER_TOPIC",
+	})
+	coalesceWindow := app.Int(cli.IntOpt{
+		Name:   "coalesceWindowMs",
+		Value:  0,
+		Desc:   "Window in milliseconds in which successive Kafka messages for the same concept are coalesced so only the latest is processed. Disabled when 0",
+		EnvVar: "KAFKA_COALESCE_WINDOW_MS",
+	})
+	sinks := app.Strings(cli.StringsOpt{
+		Name:   "sinks",
+		Value:  []string{"http"},
+		Desc:   "Where transformed concordance records are sent: any of http (concordances-rw-neo4j), kafka and file",
+		EnvVar: "CONCORDANCE_SINKS",
+	})
+	sinkTopic := app.String(cli.StringOpt{
+		Name:   "sinkTopic",
+		Value:  "ConcordanceUpdates",
+		Desc:   "Kafka topic concordance records are published to by the kafka sink",
+		EnvVar: "CONCORDANCE_SINK_TOPIC",
+	})
+	sinkFile := app.String(cli.StringOpt{
+		Name:   "sinkFile",
+		Value:  "concordances.ndjson",
+		Desc:   "File concordance records are appended to by the file sink",
+		EnvVar: "CONCORDANCE_SINK_FILE",
+	})
+	hashStorePath := app.String(cli.StringOpt{
+		Name:   "hashStorePath",
+		Value:  "",
+		Desc:   "File remembering the last concordance record sent for every concept, so unchanged records are not sent again. Disabled when empty",
+		EnvVar: "CONCORDANCE_HASH_STORE_PATH",
+	})
+	authorityConfig := app.String(cli.StringOpt{
+		Name:   "authorityConfig",
+		Value:  "",
+		Desc:   "JSON file mapping identifier predicates to concordance authorities, merged over the built-in mapping. The built-in mapping is used alone when empty",
+		EnvVar: "AUTHORITY_CONFIG_PATH",
+	})
+	conceptTypePolicy := app.String(cli.StringOpt{
+		Name:   "conceptTypePolicy",
+		Value:  "",
+		Desc:   "JSON file defining which concept types may carry concordances, and of which authorities. The built-in policy is used when empty",
+		EnvVar: "CONCEPT_TYPE_POLICY_PATH",
+	})
+	tmeTaxonomies := app.String(cli.StringOpt{
+		Name:   "tmeTaxonomies",
+		Value:  "",
+		Desc:   "JSON file listing the known TME taxonomies. TME identifiers are decoded and those of an unknown taxonomy rejected when set",
+		EnvVar: "TME_TAXONOMIES_PATH",
+	})
+	canonicalisationMode := app.String(cli.StringOpt{
+		Name:   "canonicalisationMode",
+		Value:  string(slc.CanonicalisationCompat),
+		Desc:   "compat concords Wikidata, Geonames and DBpedia identifiers as sent and reports those whose UUID would change once canonicalised; enforce concords their canonical form",
+		EnvVar: "CANONICALISATION_MODE",
+	})
+	duplicatePolicy := app.String(cli.StringOpt{
+		Name:   "duplicatePolicy",
+		Value:  "",
+		Desc:   "What happens to a concept holding two identifiers resolving to the same UUID: reject, dedupe-warn or dedupe-silent. Applies to every authority which doesn't override it in the authority config. When empty, repeated Wikidata, Geonames and DBpedia identifiers are skipped with a warning and any other fails the concept",
+		EnvVar: "DUPLICATE_POLICY",
+	})
+	guidMismatchPolicy := app.String(cli.StringOpt{
+		Name:   "guidMismatchPolicy",
+		Value:  string(slc.GUIDMismatchWarn),
+		Desc:   "What happens to a concept whose sem:guid doesn't match the UUID of its @id: warn, reject or prefer-guid",
+		EnvVar: "GUID_MISMATCH_POLICY",
+	})
+	deletedStatuses := app.Strings(cli.StringsOpt{
+		Name:   "deletedStatuses",
+		Value:  []string{"deprecated", "obsolete"},
+		Desc:   "Semaphore statuses of the concepts whose concordances are deleted instead of written. None are when empty",
+		EnvVar: "DELETED_STATUSES",
+	})
+	editorialDbpedia := app.Bool(cli.BoolOpt{
+		Name:   "editorialDbpedia",
+		Value:  false,
+		Desc:   "Concord the DBpedia identifiers of editorial concepts with the built-in authority mapping",
+		EnvVar: "EDITORIAL_DBPEDIA_ENABLED",
+	})
+	collectValidationErrors := app.Bool(cli.BoolOpt{
+		Name:   "collectValidationErrors",
+		Value:  false,
+		Desc:   "Report every invalid identifier of a concept instead of stopping at the first one",
+		EnvVar: "COLLECT_VALIDATION_ERRORS",
+	})
+	writerMaxAttempts := app.Int(cli.IntOpt{
+		Name:   "writerMaxAttempts",
+		Value:  5,
+		Desc:   "Maximum number of attempts to send a concordance record from Kafka to the writer when it fails transiently",
+		EnvVar: "WRITER_MAX_ATTEMPTS",
+	})
+	writerRetryInitialBackoff := app.Int(cli.IntOpt{
+		Name:   "writerRetryInitialBackoffMs",
+		Value:  500,
+		Desc:   "Backoff in milliseconds before the first retry of a writer request, doubled on every following retry",
+		EnvVar: "WRITER_RETRY_INITIAL_BACKOFF_MS",
+	})
+	writerRetryMaxBackoff := app.Int(cli.IntOpt{
+		Name:   "writerRetryMaxBackoffMs",
+		Value:  30000,
+		Desc:   "Maximum backoff in milliseconds between writer retries. A writer asking through Retry-After to be retried later is not retried",
+		EnvVar: "WRITER_RETRY_MAX_BACKOFF_MS",
+	})
+	concurrency := app.Int(cli.IntOpt{
+		Name:   "concurrency",
+		Value:  1,
+		Desc:   "Number of workers processing Kafka messages in parallel. Messages for the same concept are always processed in order",
+		EnvVar: "KAFKA_CONCURRENCY",
+	})
 	consumerLagTolerance := app.Int(cli.IntOpt{
 		Name:   "consumerLagTolerance",
 		Value:  120,
@@ -89,9 +205,13 @@ func main() {
 
 	app.Action = func() {
 		log.WithFields(map[string]interface{}{
-			"KAFKA_ADDRESS": *kafkaAddress,
-			"KAFKA_TOPIC":   *topic,
-			"GROUP_NAME":    *groupName,
+			"KAFKA_ADDRESS":             *kafkaAddress,
+			"KAFKA_TOPIC":               *topic,
+			"GROUP_NAME":                *groupName,
+			"KAFKA_DLQ":                 *deadLetterTopic,
+			"SINKS":                     *sinks,
+			"DELETED_STATUSES":          *deletedStatuses,
+			"EDITORIAL_DBPEDIA_ENABLED": *editorialDbpedia,
 		}).Infof("[Startup] %s is starting", *appName)
 
 		log.Infof("System code: %s, App Name: %s, Port: %s", *appSystemCode, *appName, *port)
@@ -109,8 +229,134 @@ func main() {
 			log.WithError(err).Fatal("Failed to create Kafka consumer")
 		}
 
-		transformer := slc.NewTransformerService(*topic, *writerAddress, &httpClient, log)
-		handler := slc.NewHandler(transformer, consumer, log)
+		handlerOpts := []slc.HandlerOption{
+			slc.WithConcurrency(*concurrency),
+			slc.WithCoalesceWindow(time.Duration(*coalesceWindow) * time.Millisecond),
+		}
+		if *deadLetterTopic != "" {
+			deadLetterProducer, err := kafka.NewProducer(kafka.ProducerConfig{
+				BrokersConnectionString: *kafkaAddress,
+				Topic:                   *deadLetterTopic,
+				ClusterArn:              kafkaClusterArn,
+			})
+			if err != nil {
+				log.WithError(err).Fatal("Failed to create Kafka dead letter producer")
+			}
+			defer func(producer *kafka.Producer) {
+				log.Info("Shutting down Kafka dead letter producer")
+				if err := producer.Close(); err != nil {
+					log.WithError(err).Error("Could not close kafka dead letter producer")
+				}
+			}(deadLetterProducer)
+			handlerOpts = append(handlerOpts, slc.WithDeadLetterProducer(deadLetterProducer))
+		}
+
+		var concordanceSinks []slc.ConcordanceSink
+		for _, sinkName := range *sinks {
+			switch strings.TrimSpace(sinkName) {
+			case "http":
+				concordanceSinks = append(concordanceSinks, slc.NewHTTPSink(*writerAddress, &httpClient, log))
+			case "kafka":
+				sinkProducer, err := slc.NewKafkaSinkProducer(kafka.ProducerConfig{
+					BrokersConnectionString: *kafkaAddress,
+					Topic:                   *sinkTopic,
+					ClusterArn:              kafkaClusterArn,
+				})
+				if err != nil {
+					log.WithError(err).Fatal("Failed to create Kafka concordance sink producer")
+				}
+				defer func(producer *slc.KafkaSinkProducer) {
+					log.Info("Shutting down Kafka concordance sink producer")
+					if err := producer.Close(); err != nil {
+						log.WithError(err).Error("Could not close kafka concordance sink producer")
+					}
+				}(sinkProducer)
+				concordanceSinks = append(concordanceSinks, slc.NewKafkaSink(sinkProducer, log))
+			case "file":
+				fileSink, err := slc.NewFileSink(*sinkFile, log)
+				if err != nil {
+					log.WithError(err).Fatal("Failed to open concordance sink file")
+				}
+				defer func(sink io.Closer) {
+					if err := sink.Close(); err != nil {
+						log.WithError(err).Error("Could not close concordance sink file")
+					}
+				}(fileSink.(io.Closer))
+				concordanceSinks = append(concordanceSinks, fileSink)
+			default:
+				log.Fatalf("Unknown concordance sink %q", sinkName)
+			}
+		}
+
+		retryPolicy := slc.RetryPolicy{
+			MaxAttempts:    *writerMaxAttempts,
+			InitialBackoff: time.Duration(*writerRetryInitialBackoff) * time.Millisecond,
+			MaxBackoff:     time.Duration(*writerRetryMaxBackoff) * time.Millisecond,
+		}
+		mode := slc.CanonicalisationMode(*canonicalisationMode)
+		if mode != slc.CanonicalisationCompat && mode != slc.CanonicalisationEnforce {
+			log.Fatalf("Unknown canonicalisation mode %q", *canonicalisationMode)
+		}
+		var duplicates slc.DuplicatePolicy
+		if *duplicatePolicy != "" {
+			duplicates, err = slc.ParseDuplicatePolicy(*duplicatePolicy)
+			if err != nil {
+				log.WithError(err).Fatal("Invalid duplicate policy")
+			}
+		}
+		guidMismatches, err := slc.ParseGUIDMismatchPolicy(*guidMismatchPolicy)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid guid mismatch policy")
+		}
+		transformerOpts := []slc.TransformerOption{
+			slc.WithRetryPolicy(retryPolicy),
+			slc.WithSinks(concordanceSinks...),
+			slc.WithCollectValidationErrors(*collectValidationErrors),
+			slc.WithCanonicalisationMode(mode),
+			slc.WithDuplicatePolicy(duplicates),
+			slc.WithGUIDMismatchPolicy(guidMismatches),
+			slc.WithDeletedStatuses(*deletedStatuses...),
+			slc.WithEditorialDbpedia(*editorialDbpedia),
+		}
+		if *hashStorePath != "" {
+			hashStore, err := slc.NewFileHashStore(*hashStorePath, log)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to open concordance hash store")
+			}
+			defer func(store slc.ConcordanceHashStore) {
+				if err := store.Close(); err != nil {
+					log.WithError(err).Error("Could not close concordance hash store")
+				}
+			}(hashStore)
+			transformerOpts = append(transformerOpts, slc.WithHashStore(hashStore))
+		}
+		if *authorityConfig != "" {
+			registry, err := slc.LoadAuthorityRegistry(*authorityConfig)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to load authority config")
+			}
+			transformerOpts = append(transformerOpts, slc.WithAuthorityRegistry(registry))
+		}
+		if *conceptTypePolicy != "" {
+			policies, err := slc.LoadConceptTypePolicies(*conceptTypePolicy)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to load concept type policy")
+			}
+			transformerOpts = append(transformerOpts, slc.WithConceptTypePolicies(policies))
+		}
+		if *tmeTaxonomies != "" {
+			taxonomies, err := slc.LoadTmeTaxonomies(*tmeTaxonomies)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to load TME taxonomies")
+			}
+			transformerOpts = append(transformerOpts, slc.WithTmeTaxonomies(taxonomies))
+		}
+		transformer := slc.NewTransformerService(*topic, *writerAddress, &httpClient, log, transformerOpts...)
+		handler := slc.NewHandler(transformer, consumer, log, handlerOpts...)
+		defer func() {
+			log.Info("Waiting for in flight Kafka messages to be processed")
+			handler.Close()
+		}()
 
 		router := mux.NewRouter()
 		handler.RegisterHandlers(router)
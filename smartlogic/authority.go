@@ -0,0 +1,363 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pborman/uuid"
+
+	uuidUtils "github.com/Financial-Times/uuid-utils-go"
+)
+
+const (
+	conceptModelEditorial       = "editorial"
+	conceptModelManagedLocation = "managedLocation"
+
+	validationNone     = "none"
+	validationRegex    = "regex"
+	validationTme      = "TME"
+	validationFactset  = "FACTSET"
+	validationWikidata = "wikidata"
+	validationGeonames = "geonames"
+	validationDbpedia  = "dbpedia"
+
+	validationLEI            = "LEI"
+	validationFIGI           = "FIGI"
+	validationCompaniesHouse = "companiesHouse"
+	validationISO31661Alpha2 = "iso3166-1-alpha-2"
+	validationISO31661Alpha3 = "iso3166-1-alpha-3"
+	validationISO31662       = "iso3166-2"
+
+	uuidStrategyMD5     = "md5"
+	uuidStrategyFactset = "factset"
+)
+
+const editorialDbpediaPredicate = "http://www.ft.com/ontology/dbpediaId"
+
+// AuthorityConfig maps the identifiers found under a JSON-LD predicate of a concept model to a concordance authority.
+type AuthorityConfig struct {
+	Model            string   `json:"model"`
+	Predicate        string   `json:"predicate"`
+	Authority        string   `json:"authority"`
+	Validation       string   `json:"validation,omitempty"`
+	Pattern          string   `json:"pattern,omitempty"`
+	Suffixes         []string `json:"suffixes,omitempty"`
+	UUIDStrategy     string   `json:"uuidStrategy,omitempty"`
+	Canonicalisation string   `json:"canonicalisation,omitempty"`
+	Duplicates       string   `json:"duplicates,omitempty"`
+	SkipBlank        bool     `json:"skipBlank,omitempty"`
+	Lenient          bool     `json:"lenient,omitempty"`
+	Disabled         bool     `json:"disabled,omitempty"`
+}
+
+type authorityConfigFile struct {
+	Authorities []AuthorityConfig `json:"authorities"`
+}
+
+var builtinValidators = map[string]func(string) bool{
+	validationNone:     func(string) bool { return true },
+	validationTme:      isValidTmeID,
+	validationWikidata: regexp.MustCompile(`^http://www\.wikidata\.org/entity/Q[1-9][0-9]*$`).MatchString,
+	validationGeonames: regexp.MustCompile(`^http://sws\.geonames\.org/[1-9][0-9]*/$`).MatchString,
+	validationDbpedia:  regexp.MustCompile(`^http://dbpedia\.org/resource/[^/\s]+$`).MatchString,
+
+	validationLEI:            isValidLEI,
+	validationFIGI:           isValidFIGI,
+	validationCompaniesHouse: isValidCompaniesHouseNumber,
+	validationISO31661Alpha2: regexp.MustCompile(`^[A-Z]{2}$`).MatchString,
+	validationISO31661Alpha3: regexp.MustCompile(`^[A-Z]{3}$`).MatchString,
+	validationISO31662:       regexp.MustCompile(`^[A-Z]{2}-[A-Z0-9]{1,3}$`).MatchString,
+}
+
+var uuidStrategies = map[string]func(string) string{
+	uuidStrategyMD5:     convertToUUID,
+	uuidStrategyFactset: uuidUtils.DeriveFactsetUUID,
+}
+
+var defaultAuthorityConfigs = []AuthorityConfig{
+	{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/TMEIdentifier", Authority: ConcordanceAuthorityTme, Validation: validationTme, UUIDStrategy: uuidStrategyMD5},
+	{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/factsetIdentifier", Authority: ConcordanceAuthorityFactset, Validation: validationFactset, UUIDStrategy: uuidStrategyFactset},
+	{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/geonamesIdentifier", Authority: ConcordanceAuthorityGeonames, Validation: validationGeonames, UUIDStrategy: uuidStrategyMD5, Canonicalisation: canonicalisationGeonames, SkipBlank: true, Lenient: true},
+	// rolled out with WithEditorialDbpedia
+	{Model: conceptModelEditorial, Predicate: editorialDbpediaPredicate, Authority: ConcordanceAuthorityDbpedia, Validation: validationDbpedia, UUIDStrategy: uuidStrategyMD5, Canonicalisation: canonicalisationDbpedia, SkipBlank: true, Lenient: true, Disabled: true},
+	{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/wikidataIdentifier", Authority: ConcordanceAuthorityWikidata, Validation: validationWikidata, UUIDStrategy: uuidStrategyMD5, Canonicalisation: canonicalisationWikidata, SkipBlank: true, Lenient: true},
+	{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/leiIdentifier", Authority: ConcordanceAuthorityLEI, Validation: validationLEI, UUIDStrategy: uuidStrategyMD5},
+	{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/figiIdentifier", Authority: ConcordanceAuthorityFIGI, Validation: validationFIGI, UUIDStrategy: uuidStrategyMD5},
+	{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/companiesHouseIdentifier", Authority: ConcordanceAuthorityCompaniesHouse, Validation: validationCompaniesHouse, UUIDStrategy: uuidStrategyMD5},
+	{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/TMEIdentifier", Authority: ConcordanceAuthorityTme, Validation: validationTme, UUIDStrategy: uuidStrategyMD5},
+	{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/factsetIdentifier", Authority: ConcordanceAuthorityFactset, Validation: validationFactset, UUIDStrategy: uuidStrategyFactset},
+	{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/dbpediaId", Authority: ConcordanceAuthorityDbpedia, Validation: validationDbpedia, UUIDStrategy: uuidStrategyMD5, Canonicalisation: canonicalisationDbpedia, SkipBlank: true, Lenient: true},
+	{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/geonamesId", Authority: ConcordanceAuthorityGeonames, Validation: validationGeonames, UUIDStrategy: uuidStrategyMD5, Canonicalisation: canonicalisationGeonames, SkipBlank: true, Lenient: true},
+	{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/wikidataId", Authority: ConcordanceAuthorityWikidata, Validation: validationWikidata, UUIDStrategy: uuidStrategyMD5, Canonicalisation: canonicalisationWikidata, SkipBlank: true, Lenient: true},
+	{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/iso31661Alpha2Code", Authority: ConcordanceAuthorityISO31661Alpha2, Validation: validationISO31661Alpha2, UUIDStrategy: uuidStrategyMD5, SkipBlank: true},
+	{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/iso31661Alpha3Code", Authority: ConcordanceAuthorityISO31661Alpha3, Validation: validationISO31661Alpha3, UUIDStrategy: uuidStrategyMD5, SkipBlank: true},
+	{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/iso31662Code", Authority: ConcordanceAuthorityISO31662, Validation: validationISO31662, UUIDStrategy: uuidStrategyMD5, SkipBlank: true},
+}
+
+type authorityRule struct {
+	AuthorityConfig
+	validate     func(string) bool
+	explain      func(string) string
+	canonicalise func(string) string
+	deriveUUID   func(string) string
+	duplicates   DuplicatePolicy
+}
+
+// AuthorityRegistry holds, for every concept model, the predicates read for identifiers in the order their
+// concordances are produced.
+type AuthorityRegistry struct {
+	rules      map[string][]authorityRule
+	configs    []AuthorityConfig
+	overridden map[string]bool
+}
+
+// DefaultAuthorityRegistry returns the registry of the TME, FACTSET, DBPedia, Geonames and Wikidata identifiers
+// Smartlogic sends today, of the LEI, FIGI and Companies House identifiers of organisations, and of the ISO 3166 codes
+// of managed locations. The DBpedia identifiers of editorial concepts are only concorded once enabled with
+// WithEditorialDbpedia.
+func DefaultAuthorityRegistry() *AuthorityRegistry {
+	registry, err := NewAuthorityRegistry(defaultAuthorityConfigs)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// LoadAuthorityRegistry reads a JSON file holding an "authorities" list of AuthorityConfig and merges it over the
+// built-in registry: an entry replaces the built-in one of its model and predicate, or removes it when disabled, and
+// entries for other predicates are added.
+func LoadAuthorityRegistry(path string) (*AuthorityRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading authority config %s: %w", path, err)
+	}
+	var config authorityConfigFile
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("decoding authority config %s: %w", path, err)
+	}
+	registry, err := mergeAuthorityRegistry(config.Authorities)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authority config %s: %w", path, err)
+	}
+	return registry, nil
+}
+
+// mergeAuthorityRegistry returns the built-in registry with the entries given merged over it, remembering which
+// predicates they set so enabling a built-in entry never undoes them.
+func mergeAuthorityRegistry(overrides []AuthorityConfig) (*AuthorityRegistry, error) {
+	configs, err := mergeAuthorityConfigs(defaultAuthorityConfigs, overrides)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := NewAuthorityRegistry(configs)
+	if err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		registry.overridden[override.Model+" "+override.Predicate] = true
+	}
+	return registry, nil
+}
+
+// mergeAuthorityConfigs overrides the base entries with those sharing their model and predicate, removes the ones
+// disabled and appends the others.
+func mergeAuthorityConfigs(base []AuthorityConfig, overrides []AuthorityConfig) ([]AuthorityConfig, error) {
+	merged := append([]AuthorityConfig{}, base...)
+	overridden := map[string]bool{}
+	for i, override := range overrides {
+		key := override.Model + " " + override.Predicate
+		if overridden[key] {
+			return nil, fmt.Errorf("authority %d: predicate %s is already mapped for the %s model", i, override.Predicate, override.Model)
+		}
+		overridden[key] = true
+		if !override.Disabled {
+			if _, err := newAuthorityRule(override); err != nil {
+				return nil, fmt.Errorf("authority %d: %w", i, err)
+			}
+		}
+
+		found := -1
+		for j, config := range merged {
+			if config.Model == override.Model && config.Predicate == override.Predicate {
+				found = j
+				break
+			}
+		}
+		switch {
+		case override.Disabled && found < 0:
+			return nil, fmt.Errorf("authority %d: predicate %s can't be disabled as it isn't mapped for the %s model", i, override.Predicate, override.Model)
+		case override.Disabled:
+			merged = append(merged[:found], merged[found+1:]...)
+		case found >= 0:
+			merged[found] = override
+		default:
+			merged = append(merged, override)
+		}
+	}
+	return merged, nil
+}
+
+func NewAuthorityRegistry(configs []AuthorityConfig) (*AuthorityRegistry, error) {
+	registry := &AuthorityRegistry{rules: map[string][]authorityRule{}, configs: configs, overridden: map[string]bool{}}
+	predicates := map[string]bool{}
+	for i, config := range configs {
+		if config.Disabled {
+			continue
+		}
+		rule, err := newAuthorityRule(config)
+		if err != nil {
+			return nil, fmt.Errorf("authority %d: %w", i, err)
+		}
+		key := rule.Model + " " + rule.Predicate
+		if predicates[key] {
+			return nil, fmt.Errorf("authority %d: predicate %s is already mapped for the %s model", i, rule.Predicate, rule.Model)
+		}
+		predicates[key] = true
+		registry.rules[rule.Model] = append(registry.rules[rule.Model], rule)
+	}
+	return registry, nil
+}
+
+func newAuthorityRule(config AuthorityConfig) (authorityRule, error) {
+	if config.Model != conceptModelEditorial && config.Model != conceptModelManagedLocation {
+		return authorityRule{}, fmt.Errorf("unknown concept model %q", config.Model)
+	}
+	if config.Predicate == "" || config.Authority == "" {
+		return authorityRule{}, errors.New("predicate and authority are required")
+	}
+	if config.Validation == "" {
+		config.Validation = validationNone
+	}
+	if config.UUIDStrategy == "" {
+		config.UUIDStrategy = uuidStrategyMD5
+	}
+	if config.Canonicalisation == "" {
+		config.Canonicalisation = canonicalisationNone
+	}
+
+	if len(config.Suffixes) > 0 && config.Validation != validationFactset {
+		return authorityRule{}, fmt.Errorf("suffixes are only supported by the %s validation, not by %s", validationFactset, config.Authority)
+	}
+
+	rule := authorityRule{AuthorityConfig: config}
+	if config.Validation == validationFactset {
+		validator, err := newFactsetValidator(config.Suffixes)
+		if err != nil {
+			return authorityRule{}, fmt.Errorf("%w for %s", err, config.Authority)
+		}
+		rule.validate, rule.explain = validator.valid, validator.reason
+	} else if config.Validation == validationRegex {
+		pattern, err := regexp.Compile(config.Pattern)
+		if err != nil {
+			return authorityRule{}, fmt.Errorf("invalid pattern for %s: %w", config.Authority, err)
+		}
+		rule.validate = pattern.MatchString
+	} else if validate, found := builtinValidators[config.Validation]; found {
+		rule.validate = validate
+	} else {
+		return authorityRule{}, fmt.Errorf("unknown validation %q for %s", config.Validation, config.Authority)
+	}
+	deriveUUID, found := uuidStrategies[config.UUIDStrategy]
+	if !found {
+		return authorityRule{}, fmt.Errorf("unknown uuid strategy %q for %s", config.UUIDStrategy, config.Authority)
+	}
+	rule.deriveUUID = deriveUUID
+	canonicalise, found := canonicalisers[config.Canonicalisation]
+	if !found {
+		return authorityRule{}, fmt.Errorf("unknown canonicalisation %q for %s", config.Canonicalisation, config.Authority)
+	}
+	rule.canonicalise = canonicalise
+	if config.Duplicates != "" {
+		duplicates, err := ParseDuplicatePolicy(config.Duplicates)
+		if err != nil {
+			return authorityRule{}, fmt.Errorf("%w for %s", err, config.Authority)
+		}
+		rule.duplicates = duplicates
+	}
+	return rule, nil
+}
+
+// enabled returns the registry with the disabled entry of the model and predicate enabled, unless an authority config
+// has set the predicate itself.
+func (r *AuthorityRegistry) enabled(model string, predicate string) *AuthorityRegistry {
+	key := model + " " + predicate
+	if r.overridden[key] {
+		return r
+	}
+	configs := append([]AuthorityConfig{}, r.configs...)
+	for i, config := range configs {
+		if config.Disabled && config.Model+" "+config.Predicate == key {
+			configs[i].Disabled = false
+			registry, err := NewAuthorityRegistry(configs)
+			if err != nil {
+				panic(err)
+			}
+			registry.overridden = r.overridden
+			return registry
+		}
+	}
+	return r
+}
+
+// rulesFor returns the rules of the concept model, optionally restricted to a single authority.
+func (r *AuthorityRegistry) rulesFor(model string, authority string) []authorityRule {
+	if authority == "" {
+		return r.rules[model]
+	}
+	var rules []authorityRule
+	for _, rule := range r.rules[model] {
+		if rule.Authority == authority {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// unresolvedPredicate returns a predicate of the concept which couldn't be expanded to an IRI but looks like one of
+// the identifier predicates of its model, so concordances are never deleted because of a missing @context entry.
+func (r *AuthorityRegistry) unresolvedPredicate(concept Concept) string {
+	for _, key := range concept.unresolved {
+		for _, rule := range r.rules[concept.model()] {
+			if localName(key) == localName(rule.Predicate) {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// convert validates an identifier, in its canonical form, and derives the UUID of the concept it identifies. The
+// error explains why the identifier is invalid when its validation can tell.
+func (r authorityRule) convert(value string) (string, error) {
+	canonical := r.canonicalise(value)
+	if !r.validate(canonical) {
+		message := "Bad Request: Concordance id " + value + " is not a valid " + r.Authority + " Id"
+		if r.explain != nil {
+			message += ": " + r.explain(canonical)
+		}
+		return "", errors.New(message)
+	}
+	return r.deriveUUID(value), nil
+}
+
+func isValidTmeID(tmeID string) bool {
+	subStrings := strings.Split(tmeID, "-")
+	return len(subStrings) == 2 && validateSubstrings(subStrings)
+}
+
+func convertToUUID(id string) string {
+	return uuid.NewMD5(uuid.UUID{}, []byte(id)).String()
+}
+
+func validateSubstrings(subStrings []string) bool {
+	for _, sub := range subStrings {
+		if sub == "" {
+			return false
+		}
+	}
+	return true
+}
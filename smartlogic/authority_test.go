@@ -0,0 +1,356 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAuthorityRegistry(t *testing.T) {
+	registry, err := LoadAuthorityRegistry("../resources/authorityConfig.json")
+	assert.NoError(t, err)
+	transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithAuthorityRegistry(registry))
+
+	type testStruct struct {
+		testName              string
+		payload               string
+		expectedConcordedIDs  []ConcordedID
+		expectedErrorContains string
+	}
+
+	configuredAuthority := testStruct{
+		testName: "configuredAuthority",
+		payload:  `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/organisation/Organisation"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}], "http://www.ft.com/ontology/leiCode": [{"@value": "213800MBWEIJDM5CU638"}, {"@value": "213800MBWEIJDM5CU638"}]}`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: "LEI", AuthorityValue: "213800MBWEIJDM5CU638", UUID: convertToUUID("213800MBWEIJDM5CU638")},
+			{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"},
+		},
+	}
+	builtinAuthorityIsKept := testStruct{
+		testName: "builtinAuthorityIsKept",
+		payload:  `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/organisation/Organisation"], "http://www.ft.com/ontology/wikidataIdentifier": [{"@value": "http://www.wikidata.org/entity/Q23240"}]}`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityWikidata, AuthorityValue: "http://www.wikidata.org/entity/Q23240", UUID: "76754d1e-11f6-3d4f-8e3a-59a5b4e6bdcd"},
+		},
+	}
+	disabledPredicateIsIgnored := testStruct{
+		testName:             "disabledPredicateIsIgnored",
+		payload:              `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/organisation/Organisation"], "http://www.ft.com/ontology/factsetIdentifier": [{"@value": "000D63-E"}]}`,
+		expectedConcordedIDs: []ConcordedID{},
+	}
+	patternMismatch := testStruct{
+		testName:              "patternMismatch",
+		payload:               `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/organisation/Organisation"], "http://www.ft.com/ontology/leiCode": [{"@value": "not-a-lei"}]}`,
+		expectedErrorContains: "Concordance id not-a-lei is not a valid LEI Id",
+	}
+	malformedValues := testStruct{
+		testName:              "malformedValues",
+		payload:               `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/organisation/Organisation"], "http://www.ft.com/ontology/leiCode": [42]}`,
+		expectedErrorContains: "http://www.ft.com/ontology/leiCode is not a list of JSON-LD values",
+	}
+
+	for _, scenario := range []testStruct{configuredAuthority, builtinAuthorityIsKept, disabledPredicateIsIgnored, patternMismatch, malformedValues} {
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(scenario.payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_registry")
+		if scenario.expectedErrorContains != "" {
+			assert.Equal(t, SyntacticallyIncorrect, updateStatus, "Scenario: "+scenario.testName+" failed")
+			if assert.Error(t, err, "Scenario: "+scenario.testName+" failed") {
+				assert.Contains(t, err.Error(), scenario.expectedErrorContains, "Scenario: "+scenario.testName+" failed")
+			}
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestNewAuthorityRegistryRejectsInvalidConfig(t *testing.T) {
+	type testStruct struct {
+		testName      string
+		config        AuthorityConfig
+		expectedError error
+	}
+
+	unknownModel := testStruct{testName: "unknownModel", config: AuthorityConfig{Model: "person", Predicate: "p", Authority: "A"}, expectedError: errors.New(`authority 1: unknown concept model "person"`)}
+	missingAuthority := testStruct{testName: "missingAuthority", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "p"}, expectedError: errors.New("authority 1: predicate and authority are required")}
+	unknownValidation := testStruct{testName: "unknownValidation", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "p", Authority: "A", Validation: "ISIN"}, expectedError: errors.New(`authority 1: unknown validation "ISIN" for A`)}
+	invalidPattern := testStruct{testName: "invalidPattern", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "p", Authority: "A", Validation: validationRegex, Pattern: "("}, expectedError: errors.New("authority 1: invalid pattern for A: error parsing regexp: missing closing ): `(`")}
+	unknownUUIDStrategy := testStruct{testName: "unknownUUIDStrategy", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "p", Authority: "A", UUIDStrategy: "sha1"}, expectedError: errors.New(`authority 1: unknown uuid strategy "sha1" for A`)}
+	unknownCanonicalisation := testStruct{testName: "unknownCanonicalisation", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "p", Authority: "A", Canonicalisation: "lowercase"}, expectedError: errors.New(`authority 1: unknown canonicalisation "lowercase" for A`)}
+	unknownDuplicatePolicy := testStruct{testName: "unknownDuplicatePolicy", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "p", Authority: "A", Duplicates: "merge"}, expectedError: errors.New(`authority 1: unknown duplicate policy "merge" for A`)}
+	unknownFactsetSuffix := testStruct{testName: "unknownFactsetSuffix", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "p", Authority: "A", Validation: validationFactset, Suffixes: []string{"E", "X"}}, expectedError: errors.New(`authority 1: unknown FACTSET suffix "X" for A`)}
+	suffixesWithoutFactsetValidation := testStruct{testName: "suffixesWithoutFactsetValidation", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "p", Authority: "A", Validation: validationTme, Suffixes: []string{"E"}}, expectedError: errors.New("authority 1: suffixes are only supported by the FACTSET validation, not by A")}
+	predicateMappedTwice := testStruct{testName: "predicateMappedTwice", config: AuthorityConfig{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/TMEIdentifier", Authority: "A"}, expectedError: errors.New("authority 1: predicate http://www.ft.com/ontology/TMEIdentifier is already mapped for the editorial model")}
+
+	testScenarios := []testStruct{unknownModel, missingAuthority, unknownValidation, invalidPattern, unknownUUIDStrategy, unknownCanonicalisation, unknownDuplicatePolicy, unknownFactsetSuffix, suffixesWithoutFactsetValidation, predicateMappedTwice}
+
+	for _, scenario := range testScenarios {
+		_, err := NewAuthorityRegistry([]AuthorityConfig{defaultAuthorityConfigs[0], scenario.config})
+		assert.EqualError(t, err, scenario.expectedError.Error(), "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestMergeAuthorityConfigs(t *testing.T) {
+	base := []AuthorityConfig{
+		{Model: conceptModelEditorial, Predicate: "p1", Authority: "A"},
+		{Model: conceptModelEditorial, Predicate: "p2", Authority: "B"},
+	}
+
+	type testStruct struct {
+		testName        string
+		overrides       []AuthorityConfig
+		expectedConfigs []AuthorityConfig
+		expectedError   error
+	}
+
+	overridesSamePredicate := testStruct{
+		testName:  "overridesSamePredicate",
+		overrides: []AuthorityConfig{{Model: conceptModelEditorial, Predicate: "p2", Authority: "B", Duplicates: string(DuplicatesDedupeWarn)}},
+		expectedConfigs: []AuthorityConfig{
+			{Model: conceptModelEditorial, Predicate: "p1", Authority: "A"},
+			{Model: conceptModelEditorial, Predicate: "p2", Authority: "B", Duplicates: string(DuplicatesDedupeWarn)},
+		},
+	}
+	samePredicateOfOtherModelIsAdded := testStruct{
+		testName:  "samePredicateOfOtherModelIsAdded",
+		overrides: []AuthorityConfig{{Model: conceptModelManagedLocation, Predicate: "p1", Authority: "C"}},
+		expectedConfigs: []AuthorityConfig{
+			{Model: conceptModelEditorial, Predicate: "p1", Authority: "A"},
+			{Model: conceptModelEditorial, Predicate: "p2", Authority: "B"},
+			{Model: conceptModelManagedLocation, Predicate: "p1", Authority: "C"},
+		},
+	}
+	removesDisabledPredicate := testStruct{
+		testName:        "removesDisabledPredicate",
+		overrides:       []AuthorityConfig{{Model: conceptModelEditorial, Predicate: "p1", Disabled: true}},
+		expectedConfigs: []AuthorityConfig{{Model: conceptModelEditorial, Predicate: "p2", Authority: "B"}},
+	}
+	disablingUnmappedPredicate := testStruct{
+		testName:      "disablingUnmappedPredicate",
+		overrides:     []AuthorityConfig{{Model: conceptModelManagedLocation, Predicate: "p1", Disabled: true}},
+		expectedError: errors.New("authority 0: predicate p1 can't be disabled as it isn't mapped for the managedLocation model"),
+	}
+	predicateOverriddenTwice := testStruct{
+		testName:      "predicateOverriddenTwice",
+		overrides:     []AuthorityConfig{{Model: conceptModelEditorial, Predicate: "p3", Authority: "C"}, {Model: conceptModelEditorial, Predicate: "p3", Disabled: true}},
+		expectedError: errors.New("authority 1: predicate p3 is already mapped for the editorial model"),
+	}
+	invalidOverride := testStruct{
+		testName:      "invalidOverride",
+		overrides:     []AuthorityConfig{{Model: conceptModelEditorial, Predicate: "p1", Authority: "A", Validation: "ISIN"}},
+		expectedError: errors.New(`authority 0: unknown validation "ISIN" for A`),
+	}
+
+	testScenarios := []testStruct{overridesSamePredicate, samePredicateOfOtherModelIsAdded, removesDisabledPredicate, disablingUnmappedPredicate, predicateOverriddenTwice, invalidOverride}
+
+	for _, scenario := range testScenarios {
+		configs, err := mergeAuthorityConfigs(base, scenario.overrides)
+		if scenario.expectedError != nil {
+			assert.EqualError(t, err, scenario.expectedError.Error(), "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConfigs, configs, "Scenario: "+scenario.testName+" failed")
+	}
+	assert.Len(t, base, 2, "the base entries should be left untouched")
+}
+
+func TestLocationAuthorityValidation(t *testing.T) {
+	strictConfigs := make([]AuthorityConfig, len(defaultAuthorityConfigs))
+	for i, config := range defaultAuthorityConfigs {
+		config.Lenient = false
+		strictConfigs[i] = config
+	}
+	strictRegistry, err := NewAuthorityRegistry(strictConfigs)
+	assert.NoError(t, err)
+
+	type testStruct struct {
+		testName             string
+		registry             *AuthorityRegistry
+		identifiers          string
+		expectedConcordedIDs []ConcordedID
+		expectedCode         ErrorCode
+	}
+
+	validIDs := testStruct{
+		testName:    "validIDs",
+		registry:    DefaultAuthorityRegistry(),
+		identifiers: `"http://www.ft.com/ontology/managedlocation/wikidataId": "http://www.wikidata.org/entity/Q23240", "http://www.ft.com/ontology/managedlocation/geonamesId": "http://sws.geonames.org/2649889/", "http://www.ft.com/ontology/managedlocation/dbpediaId": "http://dbpedia.org/resource/Essex"`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityDbpedia, AuthorityValue: "http://dbpedia.org/resource/Essex", UUID: convertToUUID("http://dbpedia.org/resource/Essex")},
+			{Authority: ConcordanceAuthorityGeonames, AuthorityValue: "http://sws.geonames.org/2649889/", UUID: convertToUUID("http://sws.geonames.org/2649889/")},
+			{Authority: ConcordanceAuthorityWikidata, AuthorityValue: "http://www.wikidata.org/entity/Q23240", UUID: convertToUUID("http://www.wikidata.org/entity/Q23240")},
+		},
+	}
+	nonCanonicalIDIsValid := testStruct{
+		testName:    "nonCanonicalIDIsValid",
+		registry:    strictRegistry,
+		identifiers: `"http://www.ft.com/ontology/managedlocation/wikidataId": "https://www.wikidata.org/wiki/Q23240"`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityWikidata, AuthorityValue: "https://www.wikidata.org/wiki/Q23240", UUID: convertToUUID("https://www.wikidata.org/wiki/Q23240")},
+		},
+	}
+	invalidIDsAreSkippedWhenLenient := testStruct{
+		testName:    "invalidIDsAreSkippedWhenLenient",
+		registry:    DefaultAuthorityRegistry(),
+		identifiers: `"http://www.ft.com/ontology/managedlocation/wikidataId": "Essex", "http://www.ft.com/ontology/managedlocation/geonamesId": "http://sws.geonames.org/Essex/", "http://www.ft.com/ontology/managedlocation/dbpediaId": ["Essex", "http://dbpedia.org/resource/Essex"]`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityDbpedia, AuthorityValue: "http://dbpedia.org/resource/Essex", UUID: convertToUUID("http://dbpedia.org/resource/Essex")},
+		},
+	}
+	invalidWikidataIDIsRejectedWhenStrict := testStruct{
+		testName:     "invalidWikidataIDIsRejectedWhenStrict",
+		registry:     strictRegistry,
+		identifiers:  `"http://www.ft.com/ontology/managedlocation/wikidataId": "http://www.wikidata.org/entity/P31"`,
+		expectedCode: ErrCodeInvalidWikidataID,
+	}
+	invalidGeonamesIDIsRejectedWhenStrict := testStruct{
+		testName:     "invalidGeonamesIDIsRejectedWhenStrict",
+		registry:     strictRegistry,
+		identifiers:  `"http://www.ft.com/ontology/managedlocation/geonamesId": "http://sws.geonames.org/Essex/"`,
+		expectedCode: ErrCodeInvalidGeonamesID,
+	}
+	invalidDbpediaIDIsRejectedWhenStrict := testStruct{
+		testName:     "invalidDbpediaIDIsRejectedWhenStrict",
+		registry:     strictRegistry,
+		identifiers:  `"http://www.ft.com/ontology/managedlocation/dbpediaId": "Essex"`,
+		expectedCode: ErrCodeInvalidDbpediaID,
+	}
+	validISO3166Codes := testStruct{
+		testName:    "validISO3166Codes",
+		registry:    DefaultAuthorityRegistry(),
+		identifiers: `"http://www.ft.com/ontology/managedlocation/iso31661Alpha2Code": "GB", "http://www.ft.com/ontology/managedlocation/iso31661Alpha3Code": "GBR", "http://www.ft.com/ontology/managedlocation/iso31662Code": ["GB-ESS", "GB-ENG", " "]`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityISO31661Alpha2, AuthorityValue: "GB", UUID: "79cba118-5463-350d-adba-31f172f1dc5b"},
+			{Authority: ConcordanceAuthorityISO31661Alpha3, AuthorityValue: "GBR", UUID: "a697acf8-48b7-3983-af62-cca5f77dd1f2"},
+			{Authority: ConcordanceAuthorityISO31662, AuthorityValue: "GB-ENG", UUID: "871e08ee-d58d-3018-963d-d75bf7c49475"},
+			{Authority: ConcordanceAuthorityISO31662, AuthorityValue: "GB-ESS", UUID: "c30a687f-f35c-3493-84cf-c9ae3cb5a055"},
+		},
+	}
+	lowercaseISO31661Alpha2CodeIsRejected := testStruct{
+		testName:     "lowercaseISO31661Alpha2CodeIsRejected",
+		registry:     DefaultAuthorityRegistry(),
+		identifiers:  `"http://www.ft.com/ontology/managedlocation/iso31661Alpha2Code": "gb"`,
+		expectedCode: ErrCodeInvalidISO3166Code,
+	}
+	alpha2CodeAsAlpha3IsRejected := testStruct{
+		testName:     "alpha2CodeAsAlpha3IsRejected",
+		registry:     DefaultAuthorityRegistry(),
+		identifiers:  `"http://www.ft.com/ontology/managedlocation/iso31661Alpha3Code": "GB"`,
+		expectedCode: ErrCodeInvalidISO3166Code,
+	}
+	subdivisionCodeWithoutCountryIsRejected := testStruct{
+		testName:     "subdivisionCodeWithoutCountryIsRejected",
+		registry:     DefaultAuthorityRegistry(),
+		identifiers:  `"http://www.ft.com/ontology/managedlocation/iso31662Code": "ESS"`,
+		expectedCode: ErrCodeInvalidISO3166Code,
+	}
+
+	testScenarios := []testStruct{
+		validIDs,
+		nonCanonicalIDIsValid,
+		invalidIDsAreSkippedWhenLenient,
+		invalidWikidataIDIsRejectedWhenStrict,
+		invalidGeonamesIDIsRejectedWhenStrict,
+		invalidDbpediaIDIsRejectedWhenStrict,
+		validISO3166Codes,
+		lowercaseISO31661Alpha2CodeIsRejected,
+		alpha2CodeAsAlpha3IsRejected,
+		subdivisionCodeWithoutCountryIsRejected,
+	}
+
+	for _, scenario := range testScenarios {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithAuthorityRegistry(scenario.registry))
+		payload := `{"@id": "http://www.ft.com/ontology/managedlocation/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/Location"], ` + scenario.identifiers + `}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_location")
+		if scenario.expectedCode != "" {
+			assert.Equal(t, SyntacticallyIncorrect, updateStatus, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedCode, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestEditorialDbpedia(t *testing.T) {
+	configuredRegistry, err := LoadAuthorityRegistry("../resources/authorityConfig.json")
+	assert.NoError(t, err)
+	disablingRegistry, err := mergeAuthorityRegistry([]AuthorityConfig{{Model: conceptModelEditorial, Predicate: editorialDbpediaPredicate, Disabled: true}})
+	assert.NoError(t, err)
+
+	type testStruct struct {
+		testName             string
+		opts                 []TransformerOption
+		identifiers          string
+		expectedConcordedIDs []ConcordedID
+	}
+
+	ignoredByDefault := testStruct{
+		testName:             "ignoredByDefault",
+		identifiers:          `"http://www.ft.com/ontology/dbpediaId": "http://dbpedia.org/resource/Essex"`,
+		expectedConcordedIDs: []ConcordedID{},
+	}
+	concordedWhenEnabled := testStruct{
+		testName:    "concordedWhenEnabled",
+		opts:        []TransformerOption{WithEditorialDbpedia(true)},
+		identifiers: `"http://www.ft.com/ontology/dbpediaId": "http://dbpedia.org/resource/Essex"`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityDbpedia, AuthorityValue: "http://dbpedia.org/resource/Essex", UUID: "9567fbd6-f6f3-34f4-9b31-53856d5428a3"},
+		},
+	}
+	canonicalisedWhenEnforced := testStruct{
+		testName:    "canonicalisedWhenEnforced",
+		opts:        []TransformerOption{WithEditorialDbpedia(true), WithCanonicalisationMode(CanonicalisationEnforce)},
+		identifiers: `"http://www.ft.com/ontology/dbpediaId": "https://dbpedia.org/page/Essex/"`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityDbpedia, AuthorityValue: "http://dbpedia.org/resource/Essex", UUID: "9567fbd6-f6f3-34f4-9b31-53856d5428a3"},
+		},
+	}
+	invalidAndDuplicateIDsAreSkipped := testStruct{
+		testName:    "invalidAndDuplicateIDsAreSkipped",
+		opts:        []TransformerOption{WithEditorialDbpedia(true)},
+		identifiers: `"http://www.ft.com/ontology/dbpediaId": ["Essex", "http://dbpedia.org/resource/Essex", "http://dbpedia.org/resource/Essex", " "]`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityDbpedia, AuthorityValue: "http://dbpedia.org/resource/Essex", UUID: "9567fbd6-f6f3-34f4-9b31-53856d5428a3"},
+		},
+	}
+	appliedOverAuthorityConfig := testStruct{
+		testName:    "appliedOverAuthorityConfig",
+		opts:        []TransformerOption{WithEditorialDbpedia(true), WithAuthorityRegistry(configuredRegistry)},
+		identifiers: `"http://www.ft.com/ontology/dbpediaId": "http://dbpedia.org/resource/Essex"`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityDbpedia, AuthorityValue: "http://dbpedia.org/resource/Essex", UUID: "9567fbd6-f6f3-34f4-9b31-53856d5428a3"},
+		},
+	}
+	keptDisabledByAuthorityConfig := testStruct{
+		testName:             "keptDisabledByAuthorityConfig",
+		opts:                 []TransformerOption{WithEditorialDbpedia(true), WithAuthorityRegistry(disablingRegistry)},
+		identifiers:          `"http://www.ft.com/ontology/dbpediaId": "http://dbpedia.org/resource/Essex"`,
+		expectedConcordedIDs: []ConcordedID{},
+	}
+
+	testScenarios := []testStruct{
+		ignoredByDefault,
+		concordedWhenEnabled,
+		canonicalisedWhenEnforced,
+		invalidAndDuplicateIDsAreSkipped,
+		appliedOverAuthorityConfig,
+		keptDisabledByAuthorityConfig,
+	}
+
+	for _, scenario := range testScenarios {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), scenario.opts...)
+		payload := `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/Location"], ` + scenario.identifiers + `}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		_, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_editorial_dbpedia")
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
@@ -0,0 +1,98 @@
+package smartlogic
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	canonicalisationNone     = "none"
+	canonicalisationWikidata = "wikidata"
+	canonicalisationGeonames = "geonames"
+	canonicalisationDbpedia  = "dbpedia"
+
+	canonicalisationChangesMetric = "canonicalisation_uuid_changes"
+)
+
+// CanonicalisationMode decides whether identifiers are concorded in their canonical form.
+type CanonicalisationMode string
+
+const (
+	// CanonicalisationCompat concords identifiers as sent, and reports those whose UUID would change once canonicalised.
+	CanonicalisationCompat CanonicalisationMode = "compat"
+	// CanonicalisationEnforce concords identifiers in their canonical form.
+	CanonicalisationEnforce CanonicalisationMode = "enforce"
+)
+
+var (
+	wikidataMatcher = regexp.MustCompile(`(?i)^(?:https?://(?:www\.|m\.)?wikidata\.org/(?:entity|wiki)/)?(Q[0-9]+)/?$`)
+	geonamesMatcher = regexp.MustCompile(`(?i)^(?:https?://(?:sws\.|www\.)?geonames\.org/)?([0-9]+)(?:/.*)?$`)
+	dbpediaMatcher  = regexp.MustCompile(`(?i)^https?://(?:www\.)?dbpedia\.org/(?:resource|page)/([^/]+)/?$`)
+)
+
+var canonicalisers = map[string]func(string) string{
+	canonicalisationNone:     func(value string) string { return value },
+	canonicalisationWikidata: canonicalWikidataID,
+	canonicalisationGeonames: canonicalGeonamesID,
+	canonicalisationDbpedia:  canonicalDbpediaID,
+}
+
+// WithCanonicalisationMode sets whether identifiers are concorded in their canonical form or as sent.
+func WithCanonicalisationMode(mode CanonicalisationMode) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.canonicalisationMode = mode
+	}
+}
+
+// canonicalWikidataID turns entity and wiki page URIs, and bare Q-IDs, into http://www.wikidata.org/entity/Q<n>.
+func canonicalWikidataID(value string) string {
+	match := wikidataMatcher.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return value
+	}
+	return "http://www.wikidata.org/entity/" + strings.ToUpper(match[1])
+}
+
+// canonicalGeonamesID turns feature URIs, with or without a trailing slash or document name, and bare numeric IDs
+// into http://sws.geonames.org/<n>/.
+func canonicalGeonamesID(value string) string {
+	match := geonamesMatcher.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return value
+	}
+	return "http://sws.geonames.org/" + match[1] + "/"
+}
+
+// canonicalDbpediaID turns resource and page URIs into http://dbpedia.org/resource/<name>.
+func canonicalDbpediaID(value string) string {
+	match := dbpediaMatcher.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return value
+	}
+	return "http://dbpedia.org/resource/" + match[1]
+}
+
+// canonicalValue returns the value the identifier is concorded with. In compatibility mode it is the value as sent,
+// and identifiers whose concordance UUID would change once canonicalised are logged and counted.
+func (ts *TransformerService) canonicalValue(rule authorityRule, id IdentifierValue, conceptUUID string, tid string) string {
+	canonical := rule.canonicalise(id.Value)
+	if canonical == id.Value || ts.canonicalisationMode == CanonicalisationEnforce {
+		return canonical
+	}
+	if uuidFromID, canonicalUUID := rule.deriveUUID(id.Value), rule.deriveUUID(canonical); uuidFromID != canonicalUUID {
+		metrics.GetOrRegisterCounter(canonicalisationChangesMetric, metrics.DefaultRegistry).Inc(1)
+		ts.log.WithFields(map[string]interface{}{
+			"transaction_id":  tid,
+			"UUID":            conceptUUID,
+			"authority":       rule.Authority,
+			"value":           id.Value,
+			"canonical_value": canonical,
+			"concorded_uuid":  uuidFromID,
+			"canonical_uuid":  canonicalUUID,
+			"path":            id.path,
+		}).Warn("Canonicalising the identifier would change the UUID it is concorded with")
+	}
+	return id.Value
+}
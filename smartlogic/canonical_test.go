@@ -0,0 +1,93 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalIdentifiers(t *testing.T) {
+	type testStruct struct {
+		testName         string
+		canonicalisation string
+		value            string
+		expectedValue    string
+	}
+
+	wikidataEntity := testStruct{testName: "wikidataEntity", canonicalisation: canonicalisationWikidata, value: "http://www.wikidata.org/entity/Q23240", expectedValue: "http://www.wikidata.org/entity/Q23240"}
+	wikidataHTTPS := testStruct{testName: "wikidataHTTPS", canonicalisation: canonicalisationWikidata, value: "https://www.wikidata.org/entity/Q23240", expectedValue: "http://www.wikidata.org/entity/Q23240"}
+	wikidataPage := testStruct{testName: "wikidataPage", canonicalisation: canonicalisationWikidata, value: "https://www.wikidata.org/wiki/Q23240", expectedValue: "http://www.wikidata.org/entity/Q23240"}
+	wikidataMobileHost := testStruct{testName: "wikidataMobileHost", canonicalisation: canonicalisationWikidata, value: "https://m.wikidata.org/wiki/Q23240/", expectedValue: "http://www.wikidata.org/entity/Q23240"}
+	wikidataBareID := testStruct{testName: "wikidataBareID", canonicalisation: canonicalisationWikidata, value: " q23240 ", expectedValue: "http://www.wikidata.org/entity/Q23240"}
+	wikidataProperty := testStruct{testName: "wikidataProperty", canonicalisation: canonicalisationWikidata, value: "http://www.wikidata.org/entity/P31", expectedValue: "http://www.wikidata.org/entity/P31"}
+	geonamesFeature := testStruct{testName: "geonamesFeature", canonicalisation: canonicalisationGeonames, value: "http://sws.geonames.org/2649889/", expectedValue: "http://sws.geonames.org/2649889/"}
+	geonamesNoTrailingSlash := testStruct{testName: "geonamesNoTrailingSlash", canonicalisation: canonicalisationGeonames, value: "https://sws.geonames.org/2649889", expectedValue: "http://sws.geonames.org/2649889/"}
+	geonamesPage := testStruct{testName: "geonamesPage", canonicalisation: canonicalisationGeonames, value: "https://www.geonames.org/2649889/essex.html", expectedValue: "http://sws.geonames.org/2649889/"}
+	geonamesBareID := testStruct{testName: "geonamesBareID", canonicalisation: canonicalisationGeonames, value: "2649889", expectedValue: "http://sws.geonames.org/2649889/"}
+	dbpediaResource := testStruct{testName: "dbpediaResource", canonicalisation: canonicalisationDbpedia, value: "http://dbpedia.org/resource/Essex", expectedValue: "http://dbpedia.org/resource/Essex"}
+	dbpediaPage := testStruct{testName: "dbpediaPage", canonicalisation: canonicalisationDbpedia, value: "https://dbpedia.org/page/Essex/", expectedValue: "http://dbpedia.org/resource/Essex"}
+	dbpediaBareName := testStruct{testName: "dbpediaBareName", canonicalisation: canonicalisationDbpedia, value: "Essex", expectedValue: "Essex"}
+
+	testScenarios := []testStruct{
+		wikidataEntity,
+		wikidataHTTPS,
+		wikidataPage,
+		wikidataMobileHost,
+		wikidataBareID,
+		wikidataProperty,
+		geonamesFeature,
+		geonamesNoTrailingSlash,
+		geonamesPage,
+		geonamesBareID,
+		dbpediaResource,
+		dbpediaPage,
+		dbpediaBareName,
+	}
+
+	for _, scenario := range testScenarios {
+		assert.Equal(t, scenario.expectedValue, canonicalisers[scenario.canonicalisation](scenario.value), "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestCanonicalisationModes(t *testing.T) {
+	payload := `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/Location"], "http://www.ft.com/ontology/wikidataIdentifier": [{"@value": "http://www.wikidata.org/entity/Q23240"}, {"@value": "https://www.wikidata.org/wiki/Q23240"}]}`
+	canonicalID := ConcordedID{Authority: ConcordanceAuthorityWikidata, AuthorityValue: "http://www.wikidata.org/entity/Q23240", UUID: convertToUUID("http://www.wikidata.org/entity/Q23240")}
+
+	type testStruct struct {
+		testName             string
+		mode                 CanonicalisationMode
+		expectedConcordedIDs []ConcordedID
+		expectedReported     int64
+	}
+
+	compat := testStruct{
+		testName: "compat",
+		mode:     CanonicalisationCompat,
+		expectedConcordedIDs: []ConcordedID{
+			canonicalID,
+			{Authority: ConcordanceAuthorityWikidata, AuthorityValue: "https://www.wikidata.org/wiki/Q23240", UUID: convertToUUID("https://www.wikidata.org/wiki/Q23240")},
+		},
+		expectedReported: 1,
+	}
+	enforce := testStruct{
+		testName:             "enforce",
+		mode:                 CanonicalisationEnforce,
+		expectedConcordedIDs: []ConcordedID{canonicalID},
+		expectedReported:     0,
+	}
+
+	for _, scenario := range []testStruct{compat, enforce} {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithCanonicalisationMode(scenario.mode))
+		reportedBefore := metrics.GetOrRegisterCounter(canonicalisationChangesMetric, metrics.DefaultRegistry).Count()
+
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		_, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_canonical")
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+		reported := metrics.GetOrRegisterCounter(canonicalisationChangesMetric, metrics.DefaultRegistry).Count() - reportedBefore
+		assert.Equal(t, scenario.expectedReported, reported, "Scenario: "+scenario.testName+" failed")
+	}
+}
@@ -0,0 +1,144 @@
+package smartlogic
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/rcrowley/go-metrics"
+)
+
+const supersededMessagesMetric = "superseded_concordance_messages"
+
+// conceptCoalescer holds back Kafka messages for a concept for a short window, so that when an editor saves the same
+// concept several times in a row only the latest payload is transformed and sent on.
+type conceptCoalescer struct {
+	window     time.Duration
+	forward    func(conceptKeys []string, msg kafka.FTMessage) <-chan struct{}
+	pending    map[string]*pendingMessage
+	superseded metrics.Counter
+	closed     bool
+	mu         sync.Mutex
+	log        *logger.UPPLogger
+}
+
+// pendingMessage is a message waiting for its window to end. It is registered under every one of its concepts.
+type pendingMessage struct {
+	msg         kafka.FTMessage
+	conceptKeys []string
+	timer       *time.Timer
+	// done is closed once the message, or the message superseding it, has been processed
+	done       chan struct{}
+	supersedes []chan struct{}
+}
+
+func newConceptCoalescer(window time.Duration, forward func(conceptKeys []string, msg kafka.FTMessage) <-chan struct{}, log *logger.UPPLogger) *conceptCoalescer {
+	return &conceptCoalescer{
+		window:     window,
+		forward:    forward,
+		pending:    map[string]*pendingMessage{},
+		superseded: metrics.GetOrRegisterCounter(supersededMessagesMetric, metrics.DefaultRegistry),
+		log:        log,
+	}
+}
+
+// submit coalesces the message and only returns once it, or the message superseding it, has been processed, as the
+// Kafka consumer commits the offset of a message as soon as its handler returns.
+func (c *conceptCoalescer) submit(conceptKeys []string, msg kafka.FTMessage) {
+	<-c.hold(conceptKeys, msg)
+}
+
+// hold starts a coalescing window for the concepts of the message and returns a channel closed once the message, or
+// the message superseding it, has been processed. The message supersedes the waiting messages holding only concepts
+// it holds too. A waiting message holding other concepts as well can't be superseded, so it is released straight
+// away to be processed before the new one.
+func (c *conceptCoalescer) hold(conceptKeys []string, msg kafka.FTMessage) <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(conceptKeys) == 0 || c.closed {
+		return c.forward(conceptKeys, msg)
+	}
+
+	held := &pendingMessage{msg: msg, conceptKeys: conceptKeys, done: make(chan struct{})}
+	for _, conceptKey := range conceptKeys {
+		waiting, found := c.pending[conceptKey]
+		if !found {
+			continue
+		}
+		if !containsAll(conceptKeys, waiting.conceptKeys) {
+			c.release(waiting)
+			continue
+		}
+		c.forget(waiting)
+		c.superseded.Inc(1)
+		c.log.WithTransactionID(waiting.msg.Headers[transactionIDHeader]).
+			WithFields(map[string]interface{}{"concept": conceptKey, "superseded_by": msg.Headers[transactionIDHeader]}).
+			Info("Smartlogic message superseded by a newer update of the same concept; skipping it")
+		// the forced re-send of a concept is not lost by being superseded
+		if forced(waiting.msg) && !forced(held.msg) {
+			held.msg.Headers = maps.Clone(held.msg.Headers)
+			held.msg.Headers[forceSendHeader] = "true"
+		}
+		held.supersedes = append(held.supersedes, waiting.done)
+		held.supersedes = append(held.supersedes, waiting.supersedes...)
+	}
+
+	for _, conceptKey := range conceptKeys {
+		c.pending[conceptKey] = held
+	}
+	held.timer = time.AfterFunc(c.window, func() { c.flush(held) })
+	return held.done
+}
+
+// flush releases the message once its window has ended, unless it has been superseded or released already.
+func (c *conceptCoalescer) flush(waiting *pendingMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[waiting.conceptKeys[0]] == waiting {
+		c.release(waiting)
+	}
+}
+
+// release forwards the message while holding the lock, so a newer message for the same concepts can't overtake it.
+func (c *conceptCoalescer) release(waiting *pendingMessage) {
+	c.forget(waiting)
+	processed := c.forward(waiting.conceptKeys, waiting.msg)
+	go func() {
+		<-processed
+		close(waiting.done)
+		for _, done := range waiting.supersedes {
+			close(done)
+		}
+	}()
+}
+
+func (c *conceptCoalescer) forget(waiting *pendingMessage) {
+	waiting.timer.Stop()
+	for _, conceptKey := range waiting.conceptKeys {
+		delete(c.pending, conceptKey)
+	}
+}
+
+// close releases the messages still waiting for their window to end and stops coalescing.
+func (c *conceptCoalescer) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	// releasing a message forgets all of its concepts, so each message is only met once
+	for _, waiting := range c.pending {
+		c.release(waiting)
+	}
+}
+
+// containsAll reports whether every one of the subset of concepts is in the concepts.
+func containsAll(conceptKeys []string, subset []string) bool {
+	for _, conceptKey := range subset {
+		if !slices.Contains(conceptKeys, conceptKey) {
+			return false
+		}
+	}
+	return true
+}
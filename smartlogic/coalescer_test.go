@@ -0,0 +1,177 @@
+package smartlogic
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type forwardedMessages struct {
+	mu   sync.Mutex
+	tids []string
+}
+
+func (f *forwardedMessages) forward(_ []string, msg kafka.FTMessage) <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tids = append(f.tids, msg.Headers[transactionIDHeader])
+	processed := make(chan struct{})
+	close(processed)
+	return processed
+}
+
+func (f *forwardedMessages) get() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.tids...)
+}
+
+func newTestMessage(tid string) kafka.FTMessage {
+	return kafka.NewFTMessage(map[string]string{transactionIDHeader: tid}, "")
+}
+
+func TestConceptCoalescerKeepsLatestMessagePerConcept(t *testing.T) {
+	forwarded := &forwardedMessages{}
+	c := newConceptCoalescer(time.Hour, forwarded.forward, createLogger())
+	supersededBefore := metrics.GetOrRegisterCounter(supersededMessagesMetric, metrics.DefaultRegistry).Count()
+
+	a1 := c.hold([]string{"concept-a"}, newTestMessage("tid_a1"))
+	b1 := c.hold([]string{"concept-b"}, newTestMessage("tid_b1"))
+	a2 := c.hold([]string{"concept-a"}, newTestMessage("tid_a2"))
+	a3 := c.hold([]string{"concept-a"}, newTestMessage("tid_a3"))
+	assert.Empty(t, forwarded.get(), "messages should wait for their window to end")
+	assertNotDone(t, a1, "a superseded message should wait for the message superseding it")
+
+	c.close()
+
+	assert.ElementsMatch(t, []string{"tid_a3", "tid_b1"}, forwarded.get())
+	for _, done := range []<-chan struct{}{a1, b1, a2, a3} {
+		assertDone(t, done)
+	}
+	assert.Equal(t, int64(2), metrics.GetOrRegisterCounter(supersededMessagesMetric, metrics.DefaultRegistry).Count()-supersededBefore)
+}
+
+func TestConceptCoalescerForwardsWhenWindowEnds(t *testing.T) {
+	forwarded := &forwardedMessages{}
+	c := newConceptCoalescer(20*time.Millisecond, forwarded.forward, createLogger())
+
+	c.hold([]string{"concept-a"}, newTestMessage("tid_a1"))
+	c.submit([]string{"concept-a"}, newTestMessage("tid_a2"))
+
+	assert.Eventually(t, func() bool { return len(forwarded.get()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"tid_a2"}, forwarded.get())
+
+	a3 := c.hold([]string{"concept-a"}, newTestMessage("tid_a3"))
+	c.close()
+	assertDone(t, a3)
+	assert.Equal(t, []string{"tid_a2", "tid_a3"}, forwarded.get())
+}
+
+func TestConceptCoalescerDoesNotHoldBackUnknownConcepts(t *testing.T) {
+	forwarded := &forwardedMessages{}
+	c := newConceptCoalescer(time.Hour, forwarded.forward, createLogger())
+
+	c.submit(nil, newTestMessage("tid_1"))
+	c.submit(nil, newTestMessage("tid_2"))
+	assert.Equal(t, []string{"tid_1", "tid_2"}, forwarded.get())
+
+	c.close()
+	c.submit([]string{"concept-a"}, newTestMessage("tid_3"))
+	assert.Equal(t, []string{"tid_1", "tid_2", "tid_3"}, forwarded.get())
+}
+
+func TestConceptCoalescerCoalescesPerConcept(t *testing.T) {
+	forwarded := &forwardedMessages{}
+	c := newConceptCoalescer(time.Hour, forwarded.forward, createLogger())
+
+	a1 := c.hold([]string{"concept-x"}, newTestMessage("tid_a1"))
+	b := c.hold([]string{"concept-x", "concept-y"}, newTestMessage("tid_b"))
+	assert.Empty(t, forwarded.get(), "a message holding every concept of a waiting one should supersede it")
+
+	a2 := c.hold([]string{"concept-x"}, newTestMessage("tid_a2"))
+	assert.Equal(t, []string{"tid_b"}, forwarded.get(), "a waiting message holding other concepts should be released before a newer message for one of them")
+	assertDone(t, a1)
+	assertDone(t, b)
+	assertNotDone(t, a2, "the newer message should wait for its window to end")
+
+	c.close()
+	assertDone(t, a2)
+	assert.Equal(t, []string{"tid_b", "tid_a2"}, forwarded.get())
+}
+
+func TestConceptCoalescerWaitsForProcessing(t *testing.T) {
+	processed := make(chan struct{})
+	c := newConceptCoalescer(time.Millisecond, func([]string, kafka.FTMessage) <-chan struct{} { return processed }, createLogger())
+
+	a1 := c.hold([]string{"concept-a"}, newTestMessage("tid_a1"))
+	a2 := c.hold([]string{"concept-a"}, newTestMessage("tid_a2"))
+	time.Sleep(20 * time.Millisecond)
+	assertNotDone(t, a1, "a superseded message should wait until the message superseding it has been processed")
+	assertNotDone(t, a2, "a released message should wait until it has been processed")
+
+	close(processed)
+	assertDone(t, a1)
+	assertDone(t, a2)
+	c.close()
+}
+
+func TestConceptCoalescerKeepsForcedSend(t *testing.T) {
+	var forwarded []kafka.FTMessage
+	c := newConceptCoalescer(time.Hour, func(_ []string, msg kafka.FTMessage) <-chan struct{} {
+		forwarded = append(forwarded, msg)
+		processed := make(chan struct{})
+		close(processed)
+		return processed
+	}, createLogger())
+
+	c.hold([]string{"concept-a"}, kafka.NewFTMessage(map[string]string{transactionIDHeader: "tid_a1", forceSendHeader: "true"}, ""))
+	c.hold([]string{"concept-a"}, newTestMessage("tid_a2"))
+	c.close()
+
+	if assert.Len(t, forwarded, 1) {
+		assert.Equal(t, "tid_a2", forwarded[0].Headers[transactionIDHeader])
+		assert.True(t, forced(forwarded[0]), "a message superseding a forced send should be forced too")
+	}
+}
+
+func assertDone(t *testing.T, done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("message was not handed back once processed")
+	}
+}
+
+func assertNotDone(t *testing.T, done <-chan struct{}, msg string) {
+	select {
+	case <-done:
+		t.Error(msg)
+	default:
+	}
+}
+
+func TestProcessKafkaMessageWithCoalesceWindow(t *testing.T) {
+	client := &countingHTTPClient{statusCode: 200}
+	transformer := NewTransformerService(TOPIC, WriterAddress, client, createLogger())
+	h := NewHandler(transformer, mockConsumer{}, createLogger(), WithCoalesceWindow(time.Hour))
+
+	// messages consumed from different partitions are handled concurrently
+	var wg sync.WaitGroup
+	for _, fileName := range []string{"multipleTmeIds.json", "multipleTmeIds.json", "multipleTmeIds.json", "managedLocationIds.json"} {
+		wg.Add(1)
+		go func(fileName string) {
+			defer wg.Done()
+			h.ProcessKafkaMessage(kafka.NewFTMessage(map[string]string{}, readFile(t, "../resources/"+fileName)))
+		}(fileName)
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 0, client.count())
+
+	h.Close()
+	wg.Wait()
+	assert.Equal(t, 2, client.count())
+}
@@ -0,0 +1,235 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	violationReject = "reject"
+	violationStrip  = "strip"
+	violationDelete = "delete"
+)
+
+// ConceptTypePolicy defines whether concepts of a type may carry concordances, and what happens when they don't
+// follow the policy. Type is matched against the @type of the concept as written, or against its last path segment,
+// so "Membership" matches "http://www.ft.com/ontology/organisation/Membership".
+// Authorities lists the authorities the type may carry: every authority when absent, none when empty.
+type ConceptTypePolicy struct {
+	Type        string   `json:"type"`
+	Allowed     *bool    `json:"allowed,omitempty"`
+	Authorities []string `json:"authorities"`
+	OnViolation string   `json:"onViolation,omitempty"`
+}
+
+type conceptTypePolicyFile struct {
+	ConceptTypes []ConceptTypePolicy `json:"conceptTypes"`
+	Precedence   []string            `json:"precedence,omitempty"`
+}
+
+var notAllowed = false
+
+// membershipAuthorities are the built-in authorities Membership and MembershipRole concepts may carry: every one but TME.
+var membershipAuthorities = []string{
+	ConcordanceAuthorityFactset,
+	ConcordanceAuthorityDbpedia,
+	ConcordanceAuthorityGeonames,
+	ConcordanceAuthorityWikidata,
+	ConcordanceAuthorityLEI,
+	ConcordanceAuthorityFIGI,
+	ConcordanceAuthorityCompaniesHouse,
+	ConcordanceAuthoritySmartlogic,
+	ConcordanceAuthorityISO31661Alpha2,
+	ConcordanceAuthorityISO31661Alpha3,
+	ConcordanceAuthorityISO31662,
+}
+
+var defaultConceptTypePolicies = []ConceptTypePolicy{
+	{Type: "skos:Concept", Allowed: &notAllowed, OnViolation: violationReject},
+	{Type: "Membership", Authorities: membershipAuthorities, OnViolation: violationReject},
+	{Type: "MembershipRole", Authorities: membershipAuthorities, OnViolation: violationReject},
+}
+
+// ConceptTypePolicies holds the policy of every concept type which is restricted. Types without a policy may carry
+// the concordances of every authority. Precedence ranks the types, as written or in short form, a concept may have
+// several of: the first of its types in the ranking is the type of the concept, and the policies of its types are
+// applied in that order. Types not ranked come last, in the order of the payload.
+type ConceptTypePolicies struct {
+	policies   map[string]ConceptTypePolicy
+	precedence map[string]int
+}
+
+// DefaultConceptTypePolicies returns the policies rejecting skos:Concept, and Membership and MembershipRole
+// concepts holding TME identifiers, ranked in that order.
+func DefaultConceptTypePolicies() *ConceptTypePolicies {
+	policies, err := NewConceptTypePolicies(defaultConceptTypePolicies)
+	if err != nil {
+		panic(err)
+	}
+	return policies
+}
+
+// LoadConceptTypePolicies reads the policies from a JSON file holding a "conceptTypes" list of ConceptTypePolicy, and
+// an optional "precedence" list of types.
+func LoadConceptTypePolicies(path string) (*ConceptTypePolicies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading concept type policy %s: %w", path, err)
+	}
+	var config conceptTypePolicyFile
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("decoding concept type policy %s: %w", path, err)
+	}
+	policies, err := NewConceptTypePolicies(config.ConceptTypes, config.Precedence...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid concept type policy %s: %w", path, err)
+	}
+	return policies, nil
+}
+
+// NewConceptTypePolicies returns the given policies, with the types ranked by precedence or, when there is none, in
+// the order of their policies.
+func NewConceptTypePolicies(configs []ConceptTypePolicy, precedence ...string) (*ConceptTypePolicies, error) {
+	policies := &ConceptTypePolicies{policies: map[string]ConceptTypePolicy{}, precedence: map[string]int{}}
+	for i, conceptType := range precedence {
+		if _, found := policies.precedence[conceptType]; found {
+			return nil, fmt.Errorf("precedence %d: %s is already ranked", i, conceptType)
+		}
+		policies.precedence[conceptType] = i
+	}
+	for i, policy := range configs {
+		if policy.Type == "" {
+			return nil, fmt.Errorf("concept type %d: type is required", i)
+		}
+		if _, found := policies.policies[policy.Type]; found {
+			return nil, fmt.Errorf("concept type %d: %s already has a policy", i, policy.Type)
+		}
+		if policy.OnViolation == "" {
+			policy.OnViolation = violationReject
+		}
+		if policy.OnViolation != violationReject && policy.OnViolation != violationStrip && policy.OnViolation != violationDelete {
+			return nil, fmt.Errorf("concept type %d: unknown violation action %q for %s", i, policy.OnViolation, policy.Type)
+		}
+		policies.policies[policy.Type] = policy
+		if len(precedence) == 0 {
+			policies.precedence[policy.Type] = i
+		}
+	}
+	return policies, nil
+}
+
+// WithConceptTypePolicies replaces the built-in policies restricting the concordances of concept types.
+func WithConceptTypePolicies(policies *ConceptTypePolicies) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.conceptTypes = policies
+	}
+}
+
+// policyFor returns the policy of the concept type, preferring a policy for the type as written over one for its
+// short form. Types without a policy get an unrestricted one.
+func (p *ConceptTypePolicies) policyFor(conceptType string) ConceptTypePolicy {
+	if policy, found := p.policies[conceptType]; found {
+		return policy
+	}
+	if policy, found := p.policies[shortFormType(conceptType)]; found {
+		return policy
+	}
+	return ConceptTypePolicy{Type: conceptType, OnViolation: violationReject}
+}
+
+// ordered returns the types of a concept by precedence.
+func (p *ConceptTypePolicies) ordered(conceptTypes []string) []string {
+	ordered := make([]string, len(conceptTypes))
+	copy(ordered, conceptTypes)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return p.rank(ordered[i]) < p.rank(ordered[j])
+	})
+	return ordered
+}
+
+func (p *ConceptTypePolicies) rank(conceptType string) int {
+	if rank, found := p.precedence[conceptType]; found {
+		return rank
+	}
+	if rank, found := p.precedence[shortFormType(conceptType)]; found {
+		return rank
+	}
+	return len(p.precedence)
+}
+
+func (p ConceptTypePolicy) allowed() bool {
+	return p.Allowed == nil || *p.Allowed
+}
+
+func (p ConceptTypePolicy) permits(authority string) bool {
+	if !p.allowed() {
+		return false
+	}
+	if p.Authorities == nil {
+		return true
+	}
+	for _, permitted := range p.Authorities {
+		if permitted == authority {
+			return true
+		}
+	}
+	return false
+}
+
+// violation returns the status and error of a concept not following the policy of its type, if any: either the
+// type isn't allowed at all, or the concept holds identifiers of an authority the type may not carry, including the
+// Smartlogic concepts merged into it.
+func (p ConceptTypePolicy) violation(concept Concept, conceptType string, conceptUUID string, rules []authorityRule) (status, *ConceptError) {
+	if !p.allowed() {
+		return SemanticallyIncorrect, &ConceptError{
+			Code:        ErrCodeConceptTypeNotAllowed,
+			ConceptUUID: conceptUUID,
+			Value:       conceptType,
+			Message:     errConceptTypeNotAllowed.Error(),
+			err:         errConceptTypeNotAllowed,
+		}
+	}
+	for _, rule := range rules {
+		if p.permits(rule.Authority) {
+			continue
+		}
+		if ids, _ := concept.identifiers(rule.Predicate); len(ids) == 0 {
+			continue
+		}
+		return SyntacticallyIncorrect, unsupportedConcordance(conceptType, conceptUUID, rule.Authority)
+	}
+	if len(concept.Merged) > 0 && !p.permits(ConcordanceAuthoritySmartlogic) {
+		return SyntacticallyIncorrect, unsupportedConcordance(conceptType, conceptUUID, ConcordanceAuthoritySmartlogic)
+	}
+	return ValidConcept, nil
+}
+
+// unsupportedConcordance returns the error of a concept holding identifiers of an authority its type may not carry.
+// The message is the one Membership concepts have always been rejected with; the authority is reported on its own.
+func unsupportedConcordance(conceptType string, conceptUUID string, authority string) *ConceptError {
+	return &ConceptError{
+		Code:        ErrCodeConcordanceNotSupported,
+		ConceptUUID: conceptUUID,
+		Authority:   authority,
+		Value:       conceptType,
+		Message:     fmt.Sprintf("bad Request: Concept type %s does not support concordance", shortFormType(conceptType)),
+	}
+}
+
+// permitted returns the rules of the authorities the concept type may carry.
+func (p ConceptTypePolicy) permitted(rules []authorityRule) []authorityRule {
+	var result []authorityRule
+	for _, rule := range rules {
+		if p.permits(rule.Authority) {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+func shortFormType(conceptType string) string {
+	return conceptType[strings.LastIndex(conceptType, "/")+1:]
+}
@@ -0,0 +1,236 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConceptTypePolicies(t *testing.T) {
+	policies, err := LoadConceptTypePolicies("../resources/conceptTypePolicy.json")
+	assert.NoError(t, err)
+	fileTransformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithConceptTypePolicies(policies))
+
+	tmeID := `"http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}]`
+	factsetID := `"http://www.ft.com/ontology/factsetIdentifier": [{"@value": "000D63-E"}]`
+	concordedTme := ConcordedID{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"}
+	concordedFactset := ConcordedID{Authority: ConcordanceAuthorityFactset, AuthorityValue: "000D63-E", UUID: "8d3aba95-02d9-3802-afc0-b99bb9b1139e"}
+
+	type testStruct struct {
+		testName             string
+		conceptType          string
+		policies             *ConceptTypePolicies
+		identifiers          string
+		expectedStatus       status
+		expectedConcordedIDs []ConcordedID
+		expectedCode         ErrorCode
+		expectedError        string
+	}
+
+	restricted, err := NewConceptTypePolicies([]ConceptTypePolicy{
+		{Type: "Organisation", Authorities: []string{ConcordanceAuthorityFactset}},
+		{Type: "Topic", Allowed: &notAllowed, OnViolation: violationDelete},
+	})
+	assert.NoError(t, err)
+	wikidataID := `"http://www.ft.com/ontology/wikidataIdentifier": [{"@value": "http://www.wikidata.org/entity/Q23240"}]`
+
+	unrestrictedType := testStruct{
+		testName:             "unrestrictedType",
+		conceptType:          "http://www.ft.com/ontology/Brand",
+		identifiers:          tmeID + ", " + factsetID,
+		expectedStatus:       ValidConcept,
+		expectedConcordedIDs: []ConcordedID{concordedFactset, concordedTme},
+	}
+	notAllowedTypeIsRejected := testStruct{
+		testName:       "notAllowedTypeIsRejected",
+		conceptType:    "skos:Concept",
+		identifiers:    tmeID,
+		expectedStatus: SemanticallyIncorrect,
+		expectedCode:   ErrCodeConceptTypeNotAllowed,
+		expectedError:  "concept type not allowed",
+	}
+	typeWithoutAuthoritiesIsRejected := testStruct{
+		testName:       "typeWithoutAuthoritiesIsRejected",
+		conceptType:    "http://www.ft.com/ontology/organisation/Membership",
+		identifiers:    factsetID,
+		expectedStatus: SyntacticallyIncorrect,
+		expectedCode:   ErrCodeConcordanceNotSupported,
+		expectedError:  "bad Request: Concept type Membership does not support concordance",
+	}
+	typeWithoutAuthoritiesAndIdentifiers := testStruct{
+		testName:             "typeWithoutAuthoritiesAndIdentifiers",
+		conceptType:          "http://www.ft.com/ontology/organisation/Membership",
+		identifiers:          `"http://www.ft.com/ontology/title": [{"@value": "Chairman"}]`,
+		expectedStatus:       ValidConcept,
+		expectedConcordedIDs: []ConcordedID{},
+	}
+	unsupportedAuthorityIsStripped := testStruct{
+		testName:             "unsupportedAuthorityIsStripped",
+		conceptType:          "http://www.ft.com/ontology/person/Person",
+		identifiers:          tmeID + ", " + factsetID + ", " + wikidataID,
+		expectedStatus:       ValidConcept,
+		expectedConcordedIDs: []ConcordedID{concordedFactset, concordedTme},
+	}
+	unsupportedAuthorityIsRejected := testStruct{
+		testName:       "unsupportedAuthorityIsRejected",
+		conceptType:    "http://www.ft.com/ontology/organisation/Organisation",
+		policies:       restricted,
+		identifiers:    tmeID + ", " + factsetID,
+		expectedStatus: SyntacticallyIncorrect,
+		expectedCode:   ErrCodeConcordanceNotSupported,
+		expectedError:  "bad Request: Concept type Organisation does not support concordance",
+	}
+	notAllowedTypeIsDeleted := testStruct{
+		testName:             "notAllowedTypeIsDeleted",
+		conceptType:          "http://www.ft.com/ontology/Topic",
+		policies:             restricted,
+		identifiers:          tmeID,
+		expectedStatus:       ValidConcept,
+		expectedConcordedIDs: []ConcordedID{},
+	}
+	builtinMembershipWithoutTme := testStruct{
+		testName:             "builtinMembershipWithoutTme",
+		conceptType:          "http://www.ft.com/ontology/organisation/Membership",
+		policies:             DefaultConceptTypePolicies(),
+		identifiers:          factsetID,
+		expectedStatus:       ValidConcept,
+		expectedConcordedIDs: []ConcordedID{concordedFactset},
+	}
+	builtinMembershipWithTme := testStruct{
+		testName:       "builtinMembershipWithTme",
+		conceptType:    "http://www.ft.com/ontology/organisation/Membership",
+		policies:       DefaultConceptTypePolicies(),
+		identifiers:    tmeID + ", " + factsetID,
+		expectedStatus: SyntacticallyIncorrect,
+		expectedCode:   ErrCodeConcordanceNotSupported,
+		expectedError:  "bad Request: Concept type Membership does not support concordance",
+	}
+
+	testScenarios := []testStruct{
+		unrestrictedType,
+		notAllowedTypeIsRejected,
+		typeWithoutAuthoritiesIsRejected,
+		typeWithoutAuthoritiesAndIdentifiers,
+		unsupportedAuthorityIsStripped,
+		unsupportedAuthorityIsRejected,
+		notAllowedTypeIsDeleted,
+		builtinMembershipWithoutTme,
+		builtinMembershipWithTme,
+	}
+
+	for _, scenario := range testScenarios {
+		payload := `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["` + scenario.conceptType + `"], ` + scenario.identifiers + `}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		transformer := fileTransformer
+		if scenario.policies != nil {
+			transformer = NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithConceptTypePolicies(scenario.policies))
+		}
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_policy")
+		assert.Equal(t, scenario.expectedStatus, updateStatus, "Scenario: "+scenario.testName+" failed")
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedCode, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestNewConceptTypePoliciesRejectsInvalidConfig(t *testing.T) {
+	type testStruct struct {
+		testName      string
+		policy        ConceptTypePolicy
+		expectedError error
+	}
+
+	missingType := testStruct{testName: "missingType", policy: ConceptTypePolicy{OnViolation: violationStrip}, expectedError: errors.New("concept type 1: type is required")}
+	unknownViolationAction := testStruct{testName: "unknownViolationAction", policy: ConceptTypePolicy{Type: "Person", OnViolation: "ignore"}, expectedError: errors.New(`concept type 1: unknown violation action "ignore" for Person`)}
+	typeDefinedTwice := testStruct{testName: "typeDefinedTwice", policy: ConceptTypePolicy{Type: "skos:Concept"}, expectedError: errors.New("concept type 1: skos:Concept already has a policy")}
+
+	for _, scenario := range []testStruct{missingType, unknownViolationAction, typeDefinedTwice} {
+		_, err := NewConceptTypePolicies([]ConceptTypePolicy{defaultConceptTypePolicies[0], scenario.policy})
+		assert.EqualError(t, err, scenario.expectedError.Error(), "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestConceptTypePrecedence(t *testing.T) {
+	policies, err := NewConceptTypePolicies([]ConceptTypePolicy{
+		{Type: "skos:Concept", Allowed: &notAllowed},
+		{Type: "http://www.ft.com/ontology/person/Person", Authorities: []string{ConcordanceAuthorityTme}, OnViolation: violationStrip},
+		{Type: "Organisation", Authorities: []string{ConcordanceAuthorityFactset}},
+	}, "skos:Concept", "Organisation", "http://www.ft.com/ontology/person/Person")
+	assert.NoError(t, err)
+	transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithConceptTypePolicies(policies))
+
+	identifiers := `"http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}], "http://www.ft.com/ontology/factsetIdentifier": [{"@value": "000D63-E"}]`
+	concordedTme := ConcordedID{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"}
+
+	type testStruct struct {
+		testName             string
+		conceptTypes         []string
+		expectedStatus       status
+		expectedConceptType  string
+		expectedConcordedIDs []ConcordedID
+		expectedError        string
+	}
+
+	notAllowedTypeFirst := testStruct{
+		testName:       "notAllowedTypeFirst",
+		conceptTypes:   []string{"skos:Concept", "http://www.ft.com/ontology/organisation/Organisation"},
+		expectedStatus: SemanticallyIncorrect,
+		expectedError:  "concept type not allowed",
+	}
+	notAllowedTypeLast := testStruct{
+		testName:       "notAllowedTypeLast",
+		conceptTypes:   []string{"http://www.ft.com/ontology/organisation/Organisation", "skos:Concept"},
+		expectedStatus: SemanticallyIncorrect,
+		expectedError:  "concept type not allowed",
+	}
+	everyPolicyIsApplied := testStruct{
+		testName:       "everyPolicyIsApplied",
+		conceptTypes:   []string{"http://www.ft.com/ontology/person/Person", "http://www.ft.com/ontology/organisation/Organisation"},
+		expectedStatus: SyntacticallyIncorrect,
+		expectedError:  "bad Request: Concept type Organisation does not support concordance",
+	}
+	rankedTypeIsResolved := testStruct{
+		testName:             "rankedTypeIsResolved",
+		conceptTypes:         []string{"http://www.ft.com/ontology/Brand", "http://www.ft.com/ontology/person/Person"},
+		expectedStatus:       ValidConcept,
+		expectedConceptType:  "Person",
+		expectedConcordedIDs: []ConcordedID{concordedTme},
+	}
+	unrankedTypesKeepPayloadOrder := testStruct{
+		testName:             "unrankedTypesKeepPayloadOrder",
+		conceptTypes:         []string{"http://www.ft.com/ontology/Brand", "http://www.ft.com/ontology/Location"},
+		expectedStatus:       ValidConcept,
+		expectedConceptType:  "Brand",
+		expectedConcordedIDs: []ConcordedID{{Authority: ConcordanceAuthorityFactset, AuthorityValue: "000D63-E", UUID: "8d3aba95-02d9-3802-afc0-b99bb9b1139e"}, concordedTme},
+	}
+
+	testScenarios := []testStruct{notAllowedTypeFirst, notAllowedTypeLast, everyPolicyIsApplied, rankedTypeIsResolved, unrankedTypesKeepPayloadOrder}
+
+	for _, scenario := range testScenarios {
+		conceptTypes, _ := json.Marshal(scenario.conceptTypes)
+		payload := `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ` + string(conceptTypes) + `, ` + identifiers + `}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_precedence")
+		assert.Equal(t, scenario.expectedStatus, updateStatus, "Scenario: "+scenario.testName+" failed")
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConceptType, uppConcordance.ConceptType, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestNewConceptTypePoliciesRejectsTypeRankedTwice(t *testing.T) {
+	_, err := NewConceptTypePolicies(defaultConceptTypePolicies, "Membership", "skos:Concept", "Membership")
+	assert.EqualError(t, err, "precedence 2: Membership is already ranked")
+}
@@ -0,0 +1,53 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+const messageTimestampFormat = "2006-01-02T15:04:05.000Z"
+
+type deadLetterMessage struct {
+	TransactionID string            `json:"transactionId"`
+	Status        string            `json:"status"`
+	Error         string            `json:"error"`
+	Code          ErrorCode         `json:"code"`
+	Errors        []ValidationIssue `json:"errors,omitempty"`
+	Headers       map[string]string `json:"headers"`
+	Body          string            `json:"body"`
+}
+
+func (h *ConcordanceTransformerHandler) sendToDeadLetterTopic(msg kafka.FTMessage, tid string, updateStatus status, processingErr error) {
+	if h.deadLetterProducer == nil {
+		return
+	}
+
+	logEntry := h.log.WithTransactionID(tid).WithField("status", updateStatus.String())
+
+	body, err := json.Marshal(deadLetterMessage{
+		TransactionID: tid,
+		Status:        updateStatus.String(),
+		Error:         processingErr.Error(),
+		Code:          errorCode(processingErr, updateStatus),
+		Errors:        validationIssues(processingErr),
+		Headers:       msg.Headers,
+		Body:          msg.Body,
+	})
+	if err != nil {
+		logEntry.WithError(err).Error("Could not marshal dead letter message")
+		return
+	}
+
+	headers := map[string]string{
+		"X-Request-Id":      tid,
+		"Message-Timestamp": time.Now().UTC().Format(messageTimestampFormat),
+		"Content-Type":      "application/json",
+	}
+	if err = h.deadLetterProducer.SendMessage(kafka.NewFTMessage(headers, string(body))); err != nil {
+		logEntry.WithError(err).Error("Failed to send message to dead letter topic")
+		return
+	}
+	logEntry.Info("Message which could not be processed was sent to dead letter topic")
+}
@@ -0,0 +1,97 @@
+package smartlogic
+
+import (
+	"fmt"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// DuplicatePolicy decides what happens to a concept holding two identifiers resolving to the same concordance UUID.
+type DuplicatePolicy string
+
+const (
+	// DuplicatesReject fails the concept with a DUPLICATE_IDENTIFIER error.
+	DuplicatesReject DuplicatePolicy = "reject"
+	// DuplicatesDedupeWarn keeps the first identifier and logs a warning for every repeated one.
+	DuplicatesDedupeWarn DuplicatePolicy = "dedupe-warn"
+	// DuplicatesDedupeSilent keeps the first identifier and drops the repeated ones without logging them.
+	DuplicatesDedupeSilent DuplicatePolicy = "dedupe-silent"
+
+	duplicatesRejectedMetric     = "duplicate_identifiers_rejected"
+	duplicatesDeduplicatedMetric = "duplicate_identifiers_deduplicated"
+)
+
+// legacyDuplicatePolicies are the values authority configs used before every authority shared the same policies.
+var legacyDuplicatePolicies = map[string]DuplicatePolicy{
+	"error": DuplicatesReject,
+	"skip":  DuplicatesDedupeWarn,
+}
+
+// DuplicateIdentifier is an identifier dropped because the concept already holds a concordance with the same UUID.
+type DuplicateIdentifier struct {
+	Authority      string `json:"authority"`
+	AuthorityValue string `json:"authorityValue"`
+	UUID           string `json:"uuid"`
+	Path           string `json:"path,omitempty"`
+}
+
+// ParseDuplicatePolicy returns the policy named by value, accepting the legacy error and skip values.
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	if policy, found := legacyDuplicatePolicies[value]; found {
+		return policy, nil
+	}
+	switch policy := DuplicatePolicy(value); policy {
+	case DuplicatesReject, DuplicatesDedupeWarn, DuplicatesDedupeSilent:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy %q", value)
+	}
+}
+
+// WithDuplicatePolicy sets the duplicate policy of every authority which doesn't override it in the registry. Without
+// it every authority keeps its legacy policy.
+func WithDuplicatePolicy(policy DuplicatePolicy) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.duplicatePolicy = policy
+	}
+}
+
+// duplicatePolicyFor returns the duplicate policy of the authority, falling back to the policy of the service, and
+// then to the legacy policy of the authority.
+func (ts *TransformerService) duplicatePolicyFor(rule authorityRule) DuplicatePolicy {
+	if rule.duplicates != "" {
+		return rule.duplicates
+	}
+	if ts.duplicatePolicy != "" {
+		return ts.duplicatePolicy
+	}
+	return legacyDuplicatePolicy(rule.Authority)
+}
+
+// legacyDuplicatePolicy returns the policy authorities followed before the duplicate policy could be set: repeated
+// Wikidata, Geonames and DBpedia identifiers are skipped with a warning, and any other repeated identifier fails the
+// concept.
+func legacyDuplicatePolicy(authority string) DuplicatePolicy {
+	switch authority {
+	case ConcordanceAuthorityWikidata, ConcordanceAuthorityGeonames, ConcordanceAuthorityDbpedia:
+		return DuplicatesDedupeWarn
+	default:
+		return DuplicatesReject
+	}
+}
+
+// deduplicate counts and, unless the policy is silent, logs an identifier dropped because it repeats a concordance
+// of the concept.
+func (ts *TransformerService) deduplicate(rule authorityRule, duplicate DuplicateIdentifier, conceptUUID string, tid string) DuplicateIdentifier {
+	metrics.GetOrRegisterCounter(duplicatesDeduplicatedMetric, metrics.DefaultRegistry).Inc(1)
+	if ts.duplicatePolicyFor(rule) != DuplicatesDedupeSilent {
+		ts.log.WithFields(map[string]interface{}{
+			"transaction_id": tid,
+			"UUID":           conceptUUID,
+			"authority":      duplicate.Authority,
+			"value":          duplicate.AuthorityValue,
+			"path":           duplicate.Path,
+		}).Warn(fmt.Sprintf("Payload from Smartlogic contains duplicate %v values. Skipping it", rule.Authority))
+	}
+	return duplicate
+}
@@ -0,0 +1,131 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	type testStruct struct {
+		testName       string
+		value          string
+		expectedPolicy DuplicatePolicy
+		expectedError  string
+	}
+
+	reject := testStruct{testName: "reject", value: "reject", expectedPolicy: DuplicatesReject}
+	dedupeWarn := testStruct{testName: "dedupeWarn", value: "dedupe-warn", expectedPolicy: DuplicatesDedupeWarn}
+	dedupeSilent := testStruct{testName: "dedupeSilent", value: "dedupe-silent", expectedPolicy: DuplicatesDedupeSilent}
+	legacyError := testStruct{testName: "legacyError", value: "error", expectedPolicy: DuplicatesReject}
+	legacySkip := testStruct{testName: "legacySkip", value: "skip", expectedPolicy: DuplicatesDedupeWarn}
+	unknown := testStruct{testName: "unknown", value: "merge", expectedError: `unknown duplicate policy "merge"`}
+
+	for _, scenario := range []testStruct{reject, dedupeWarn, dedupeSilent, legacyError, legacySkip, unknown} {
+		policy, err := ParseDuplicatePolicy(scenario.value)
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedPolicy, policy, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestDuplicatePolicies(t *testing.T) {
+	tmeID := "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"
+	payload := `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/product/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "` + tmeID + `"}, {"@value": "` + tmeID + `"}]}`
+	concorded := []ConcordedID{{Authority: ConcordanceAuthorityTme, AuthorityValue: tmeID, UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"}}
+	duplicate := []DuplicateIdentifier{{
+		Authority:      ConcordanceAuthorityTme,
+		AuthorityValue: tmeID,
+		UUID:           "e9f4525a-401f-3b23-a68e-e48f314cdce6",
+		Path:           "$['@graph'][0]['http://www.ft.com/ontology/TMEIdentifier'][1]['@value']",
+	}}
+
+	rejectingRegistry, err := NewAuthorityRegistry([]AuthorityConfig{
+		{Model: conceptModelEditorial, Predicate: "http://www.ft.com/ontology/TMEIdentifier", Authority: ConcordanceAuthorityTme, Validation: validationTme, Duplicates: string(DuplicatesReject)},
+	})
+	assert.NoError(t, err)
+
+	type testStruct struct {
+		testName             string
+		opts                 []TransformerOption
+		expectedConcordedIDs []ConcordedID
+		expectedDuplicates   []DuplicateIdentifier
+		expectedError        string
+		expectedRejected     int64
+		expectedDeduplicated int64
+	}
+
+	rejectedByDefault := testStruct{
+		testName:         "rejectedByDefault",
+		expectedError:    "bad Request: Payload from smartlogic contains duplicate TME id values",
+		expectedRejected: 1,
+	}
+	dedupeWarnAppliesToEveryAuthority := testStruct{
+		testName:             "dedupeWarnAppliesToEveryAuthority",
+		opts:                 []TransformerOption{WithDuplicatePolicy(DuplicatesDedupeWarn)},
+		expectedConcordedIDs: concorded,
+		expectedDuplicates:   duplicate,
+		expectedDeduplicated: 1,
+	}
+	dedupeSilent := testStruct{
+		testName:             "dedupeSilent",
+		opts:                 []TransformerOption{WithDuplicatePolicy(DuplicatesDedupeSilent)},
+		expectedConcordedIDs: concorded,
+		expectedDuplicates:   duplicate,
+		expectedDeduplicated: 1,
+	}
+	authorityOverride := testStruct{
+		testName:         "authorityOverride",
+		opts:             []TransformerOption{WithDuplicatePolicy(DuplicatesDedupeWarn), WithAuthorityRegistry(rejectingRegistry)},
+		expectedError:    "bad Request: Payload from smartlogic contains duplicate TME id values",
+		expectedRejected: 1,
+	}
+
+	testScenarios := []testStruct{rejectedByDefault, dedupeWarnAppliesToEveryAuthority, dedupeSilent, authorityOverride}
+
+	for _, scenario := range testScenarios {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), scenario.opts...)
+		rejectedBefore := metrics.GetOrRegisterCounter(duplicatesRejectedMetric, metrics.DefaultRegistry).Count()
+		deduplicatedBefore := metrics.GetOrRegisterCounter(duplicatesDeduplicatedMetric, metrics.DefaultRegistry).Count()
+
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_duplicates")
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, ErrCodeDuplicateIdentifier, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+		} else {
+			assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedDuplicates, uppConcordance.Duplicates, "Scenario: "+scenario.testName+" failed")
+		}
+		rejected := metrics.GetOrRegisterCounter(duplicatesRejectedMetric, metrics.DefaultRegistry).Count() - rejectedBefore
+		deduplicated := metrics.GetOrRegisterCounter(duplicatesDeduplicatedMetric, metrics.DefaultRegistry).Count() - deduplicatedBefore
+		assert.Equal(t, scenario.expectedRejected, rejected, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedDeduplicated, deduplicated, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestHandlersReportDuplicates(t *testing.T) {
+	r := mux.NewRouter()
+	transformer := NewTransformerService(TOPIC, WriterAddress, &mockHTTPClient{statusCode: 200}, createLogger(), WithDuplicatePolicy(DuplicatesDedupeSilent))
+	h := NewHandler(transformer, mockConsumer{}, createLogger())
+	h.RegisterHandlers(r)
+
+	payload := `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/product/Brand"], "http://www.ft.com/ontology/factsetIdentifier": [{"@value": "000D63-E"}, {"@value": "000D63-E"}]}]}`
+	expectedDuplicates := `"duplicates":[{"authority":"FACTSET","authorityValue":"000D63-E","uuid":"8d3aba95-02d9-3802-afc0-b99bb9b1139e","path":"$['@graph'][0]['http://www.ft.com/ontology/factsetIdentifier'][1]['@value']"}]`
+
+	for _, endpoint := range []string{"/transform", "/transform/send"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newRequest("POST", endpoint, payload))
+		assert.Equal(t, 200, rec.Code, "Endpoint: "+endpoint+" failed")
+		assert.Contains(t, rec.Body.String(), expectedDuplicates, "Endpoint: "+endpoint+" failed")
+	}
+}
@@ -0,0 +1,56 @@
+package smartlogic
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// sortConcordedIDs puts concordances in canonical order: by authority, then authority value, then UUID for
+// concordances without a value.
+func sortConcordedIDs(concordedIDs []ConcordedID) {
+	sort.SliceStable(concordedIDs, func(i, j int) bool {
+		a, b := concordedIDs[i], concordedIDs[j]
+		if a.Authority != b.Authority {
+			return a.Authority < b.Authority
+		}
+		if a.AuthorityValue != b.AuthorityValue {
+			return a.AuthorityValue < b.AuthorityValue
+		}
+		return a.UUID < b.UUID
+	})
+}
+
+// encodeUppConcordance is the canonical JSON encoding of a concordance record as sent downstream: compact, without
+// HTML escaping or a trailing newline, with its concordances in canonical order and an empty list when it has none.
+// Two semantically equal records are always encoded to the same bytes. What is only reported by the transform
+// endpoints is left out.
+func encodeUppConcordance(uppConcordance UppConcordance) ([]byte, error) {
+	uppConcordance.ConceptType, uppConcordance.Duplicates = "", nil
+	return encodeTransformedConcordance(uppConcordance)
+}
+
+// encodeTransformedConcordance encodes a concordance record like encodeUppConcordance, keeping what is only reported
+// by the transform endpoints.
+func encodeTransformedConcordance(uppConcordance UppConcordance) ([]byte, error) {
+	concordedIDs := make([]ConcordedID, len(uppConcordance.ConcordedIds))
+	copy(concordedIDs, uppConcordance.ConcordedIds)
+	sortConcordedIDs(concordedIDs)
+	uppConcordance.ConcordedIds = concordedIDs
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(uppConcordance); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// encodeRecord encodes what is sent to a sink, using the canonical encoding for concordance records.
+func encodeRecord(record interface{}) ([]byte, error) {
+	if uppConcordance, ok := record.(UppConcordance); ok {
+		return encodeUppConcordance(uppConcordance)
+	}
+	return json.Marshal(record)
+}
@@ -0,0 +1,74 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeUppConcordance(t *testing.T) {
+	type testStruct struct {
+		testName       string
+		uppConcordance UppConcordance
+		expectedJSON   string
+	}
+
+	sorted := testStruct{
+		testName: "sorted",
+		uppConcordance: UppConcordance{Authority: "Smartlogic", ConceptUUID: "20db1bd6-59f9-4404-adb5-3165a448f8b0", ConcordedIds: []ConcordedID{
+			{Authority: ConcordanceAuthorityTme, AuthorityValue: "ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321", UUID: "83f63c7e-1641-3c7b-81e4-378ae3c6c2ad"},
+			{Authority: ConcordanceAuthorityFactset, AuthorityValue: "000D63-E", UUID: "8d3aba95-02d9-3802-afc0-b99bb9b1139e"},
+			{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"},
+		}},
+		expectedJSON: `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","concordances":[{"authority":"FACTSET","authorityValue":"000D63-E","uuid":"8d3aba95-02d9-3802-afc0-b99bb9b1139e"},{"authority":"TME","authorityValue":"AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789","uuid":"e9f4525a-401f-3b23-a68e-e48f314cdce6"},{"authority":"TME","authorityValue":"ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321","uuid":"83f63c7e-1641-3c7b-81e4-378ae3c6c2ad"}]}`,
+	}
+	noConcordances := testStruct{
+		testName:       "noConcordances",
+		uppConcordance: UppConcordance{Authority: "Smartlogic", ConceptUUID: "20db1bd6-59f9-4404-adb5-3165a448f8b0"},
+		expectedJSON:   `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","concordances":[]}`,
+	}
+	notHTMLEscaped := testStruct{
+		testName: "notHTMLEscaped",
+		uppConcordance: UppConcordance{Authority: "ManagedLocation", ConceptUUID: "20db1bd6-59f9-4404-adb5-3165a448f8b0", ConcordedIds: []ConcordedID{
+			{Authority: ConcordanceAuthorityDbpedia, AuthorityValue: "http://dbpedia.org/resource/Trinidad_&_Tobago", UUID: "9567fbd6-f6f3-34f4-9b31-53856d5428a3"},
+		}},
+		expectedJSON: `{"authority":"ManagedLocation","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","concordances":[{"authority":"DBPedia","authorityValue":"http://dbpedia.org/resource/Trinidad_&_Tobago","uuid":"9567fbd6-f6f3-34f4-9b31-53856d5428a3"}]}`,
+	}
+
+	duplicatesNotSent := testStruct{
+		testName: "duplicatesNotSent",
+		uppConcordance: UppConcordance{Authority: "Smartlogic", ConceptUUID: "20db1bd6-59f9-4404-adb5-3165a448f8b0",
+			ConcordedIds: []ConcordedID{{Authority: ConcordanceAuthorityFactset, AuthorityValue: "000D63-E", UUID: "8d3aba95-02d9-3802-afc0-b99bb9b1139e"}},
+			Duplicates:   []DuplicateIdentifier{{Authority: ConcordanceAuthorityFactset, AuthorityValue: "000D63-E", UUID: "8d3aba95-02d9-3802-afc0-b99bb9b1139e"}},
+		},
+		expectedJSON: `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","concordances":[{"authority":"FACTSET","authorityValue":"000D63-E","uuid":"8d3aba95-02d9-3802-afc0-b99bb9b1139e"}]}`,
+	}
+
+	for _, scenario := range []testStruct{sorted, noConcordances, notHTMLEscaped, duplicatesNotSent} {
+		encoded, err := encodeUppConcordance(scenario.uppConcordance)
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedJSON, string(encoded), "Scenario: "+scenario.testName+" failed")
+	}
+	assert.Equal(t, ConcordanceAuthorityTme, sorted.uppConcordance.ConcordedIds[0].Authority, "encoding must not reorder the concordances of the caller")
+}
+
+func TestConcordancesDoNotDependOnPayloadOrder(t *testing.T) {
+	payloads := []string{
+		`{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/product/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}, {"@value": "ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321"}], "http://www.ft.com/ontology/FactsetIdentifier": [{"@value": "000D63-E"}]}`,
+		`{"http://www.ft.com/ontology/FactsetIdentifier": [{"@value": "000D63-E"}], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321"}, {"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}], "@type": ["http://www.ft.com/ontology/product/Brand"], "@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0"}`,
+	}
+
+	transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger())
+	var encodings []string
+	for _, payload := range payloads {
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept))
+		_, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_order")
+		assert.NoError(t, err)
+		encoded, err := encodeUppConcordance(uppConcordance)
+		assert.NoError(t, err)
+		encodings = append(encodings, string(encoded))
+	}
+	assert.Equal(t, encodings[0], encodings[1])
+}
@@ -0,0 +1,154 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorCode is a stable, machine readable identifier of why a concept couldn't be transformed or sent.
+// Codes are part of the API: clients react to them rather than to messages, so they must never be renamed.
+type ErrorCode string
+
+const (
+	ErrCodeInvalidJSON             ErrorCode = "INVALID_JSON"
+	ErrCodeMissingGraph            ErrorCode = "MISSING_GRAPH"
+	ErrCodeInvalidConceptID        ErrorCode = "INVALID_CONCEPT_ID"
+	ErrCodeMissingConceptType      ErrorCode = "MISSING_CONCEPT_TYPE"
+	ErrCodeGUIDMismatch            ErrorCode = "GUID_MISMATCH"
+	ErrCodeConceptTypeNotAllowed   ErrorCode = "CONCEPT_TYPE_NOT_ALLOWED"
+	ErrCodeConcordanceNotSupported ErrorCode = "CONCORDANCE_NOT_SUPPORTED"
+	ErrCodeUnresolvedPredicate     ErrorCode = "UNRESOLVED_PREDICATE"
+	ErrCodeMalformedIdentifier     ErrorCode = "MALFORMED_IDENTIFIER"
+	ErrCodeInvalidIdentifier       ErrorCode = "INVALID_IDENTIFIER"
+	ErrCodeInvalidTmeID            ErrorCode = "INVALID_TME_ID"
+	ErrCodeInvalidFactsetID        ErrorCode = "INVALID_FACTSET_ID"
+	ErrCodeInvalidWikidataID       ErrorCode = "INVALID_WIKIDATA_ID"
+	ErrCodeInvalidGeonamesID       ErrorCode = "INVALID_GEONAMES_ID"
+	ErrCodeInvalidDbpediaID        ErrorCode = "INVALID_DBPEDIA_ID"
+	ErrCodeInvalidLEI              ErrorCode = "INVALID_LEI_ID"
+	ErrCodeInvalidFIGI             ErrorCode = "INVALID_FIGI_ID"
+	ErrCodeInvalidCompaniesHouseID ErrorCode = "INVALID_COMPANIES_HOUSE_ID"
+	ErrCodeInvalidISO3166Code      ErrorCode = "INVALID_ISO_3166_CODE"
+	ErrCodeDuplicateIdentifier     ErrorCode = "DUPLICATE_IDENTIFIER"
+	ErrCodeUUIDCollision           ErrorCode = "UUID_COLLISION"
+	ErrCodeMergeCycle              ErrorCode = "MERGE_CYCLE"
+	ErrCodeWriterUnavailable       ErrorCode = "WRITER_UNAVAILABLE"
+	ErrCodeInternal                ErrorCode = "INTERNAL_ERROR"
+
+	problemContentType = "application/problem+json"
+)
+
+// ConceptError is an error of a concept carrying a stable code, and the identifier it is about if any.
+type ConceptError struct {
+	Code        ErrorCode
+	ConceptUUID string
+	Authority   string
+	Value       string
+	Message     string
+	err         error
+}
+
+func (e *ConceptError) Error() string {
+	return e.Message
+}
+
+func (e *ConceptError) Unwrap() error {
+	return e.err
+}
+
+// invalidIdentifierCode returns the code reported for an identifier rejected by the validation of its authority.
+func invalidIdentifierCode(authority string) ErrorCode {
+	switch authority {
+	case ConcordanceAuthorityTme:
+		return ErrCodeInvalidTmeID
+	case ConcordanceAuthorityFactset:
+		return ErrCodeInvalidFactsetID
+	case ConcordanceAuthorityWikidata:
+		return ErrCodeInvalidWikidataID
+	case ConcordanceAuthorityGeonames:
+		return ErrCodeInvalidGeonamesID
+	case ConcordanceAuthorityDbpedia:
+		return ErrCodeInvalidDbpediaID
+	case ConcordanceAuthorityLEI:
+		return ErrCodeInvalidLEI
+	case ConcordanceAuthorityFIGI:
+		return ErrCodeInvalidFIGI
+	case ConcordanceAuthorityCompaniesHouse:
+		return ErrCodeInvalidCompaniesHouseID
+	case ConcordanceAuthorityISO31661Alpha2, ConcordanceAuthorityISO31661Alpha3, ConcordanceAuthorityISO31662:
+		return ErrCodeInvalidISO3166Code
+	default:
+		return ErrCodeInvalidIdentifier
+	}
+}
+
+// errorCode returns the code of the first typed error in the tree of err, falling back to a code derived from the
+// status for errors reported by the writers.
+func errorCode(err error, updateStatus status) ErrorCode {
+	err = firstFailure(err)
+	var conceptErr *ConceptError
+	if errors.As(err, &conceptErr) {
+		return conceptErr.Code
+	}
+	if issues := validationIssues(err); len(issues) > 0 {
+		return issues[0].Code
+	}
+	if updateStatus == ServiceUnavailable {
+		return ErrCodeWriterUnavailable
+	}
+	return ErrCodeInternal
+}
+
+// problem is an RFC 9457 problem details document, extended with the code of the error and the identifier or the
+// invalid identifiers it is about.
+type problem struct {
+	Type        string            `json:"type"`
+	Title       string            `json:"title"`
+	Status      int               `json:"status"`
+	Detail      string            `json:"detail"`
+	Code        ErrorCode         `json:"code"`
+	ConceptUUID string            `json:"uuid,omitempty"`
+	Authority   string            `json:"authority,omitempty"`
+	Value       string            `json:"value,omitempty"`
+	Errors      []ValidationIssue `json:"errors,omitempty"`
+}
+
+func newProblem(statusCode int, code ErrorCode, err error) problem {
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: err.Error(),
+		Code:   code,
+		Errors: validationIssues(err),
+	}
+	err = firstFailure(err)
+	var conceptErr *ConceptError
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &conceptErr):
+		p.ConceptUUID, p.Authority, p.Value = conceptErr.ConceptUUID, conceptErr.Authority, conceptErr.Value
+	case errors.As(err, &validationErr):
+		p.ConceptUUID = validationErr.ConceptUUID
+		if len(validationErr.Issues) == 1 {
+			p.Authority, p.Value = validationErr.Issues[0].Authority, validationErr.Issues[0].Value
+		}
+	}
+	return p
+}
+
+// firstFailure returns the failure of the first concept of the joined failures of a multi-concept payload, which the
+// status is taken from, so the code and the concept reported agree with the status.
+func firstFailure(err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok && len(joined.Unwrap()) > 0 {
+		return joined.Unwrap()[0]
+	}
+	return err
+}
+
+func writeProblem(w http.ResponseWriter, p problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,115 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorsAreReportedAsProblemDetails(t *testing.T) {
+	r := mux.NewRouter()
+	mockClient := mockHTTPClient{resp: "", statusCode: 503}
+	h := NewHandler(NewTransformerService(TOPIC, WriterAddress, &mockClient, createLogger()), mockConsumer{}, createLogger())
+	h.RegisterHandlers(r)
+
+	quotedTmeID := `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "Ab\"Cd\\Ef"}]}]}`
+
+	type testStruct struct {
+		scenarioName    string
+		payload         string
+		endpoint        string
+		expectedProblem problem
+	}
+
+	invalidJSON := testStruct{
+		scenarioName:    "invalidJSON",
+		payload:         `{"@graph": `,
+		endpoint:        "/transform",
+		expectedProblem: problem{Status: 400, Code: ErrCodeInvalidJSON, Detail: "Error whilst processing request body: unexpected EOF"},
+	}
+	missingGraph := testStruct{
+		scenarioName:    "missingGraph",
+		payload:         readFile(t, "../resources/missingIdField.json"),
+		endpoint:        "/transform",
+		expectedProblem: problem{Status: 422, Code: ErrCodeMissingGraph, Detail: "invalid Request Json: Missing/invalid @graph field"},
+	}
+	conceptTypeNotAllowed := testStruct{
+		scenarioName:    "conceptTypeNotAllowed",
+		payload:         readFile(t, "../resources/notAllowedType.json"),
+		endpoint:        "/transform",
+		expectedProblem: problem{Status: 422, Code: ErrCodeConceptTypeNotAllowed, Detail: "concept type not allowed", ConceptUUID: testUUID, Value: "skos:Concept"},
+	}
+	uuidCollision := testStruct{
+		scenarioName: "uuidCollision",
+		payload:      readFile(t, "../resources/tmeGeneratedUuidEqualConceptUuid.json"),
+		endpoint:     "/transform",
+		expectedProblem: problem{
+			Status:      400,
+			Code:        ErrCodeUUIDCollision,
+			Detail:      "bad Request: Payload from smartlogic has a smartlogic uuid that is the same as the uuid generated from the TME id",
+			ConceptUUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6",
+			Authority:   ConcordanceAuthorityTme,
+			Value:       "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789",
+		},
+	}
+	escapedInvalidTmeID := testStruct{
+		scenarioName: "escapedInvalidTmeID",
+		payload:      quotedTmeID,
+		endpoint:     "/transform",
+		expectedProblem: problem{
+			Status:      400,
+			Code:        ErrCodeInvalidTmeID,
+			Detail:      `Bad Request: Concordance id Ab"Cd\Ef is not a valid TME Id`,
+			ConceptUUID: testUUID,
+			Authority:   ConcordanceAuthorityTme,
+			Value:       `Ab"Cd\Ef`,
+		},
+	}
+	writerUnavailable := testStruct{
+		scenarioName:    "writerUnavailable",
+		payload:         readFile(t, "../resources/noTmeIds.json"),
+		endpoint:        "/transform/send",
+		expectedProblem: problem{Status: 500, Code: ErrCodeInternal, Detail: "Internal Error: Delete request to writer returned unexpected status: 503"},
+	}
+
+	testScenarios := []testStruct{invalidJSON, missingGraph, conceptTypeNotAllowed, uuidCollision, escapedInvalidTmeID, writerUnavailable}
+
+	for _, scenario := range testScenarios {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newRequest("POST", scenario.endpoint, scenario.payload))
+		assert.Equal(t, scenario.expectedProblem.Status, rec.Code, "Scenario: "+scenario.scenarioName+" failed")
+		assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"), "Scenario: "+scenario.scenarioName+" failed")
+
+		var actual problem
+		if !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &actual), "Scenario: "+scenario.scenarioName+" failed") {
+			continue
+		}
+		assert.Equal(t, "about:blank", actual.Type, "Scenario: "+scenario.scenarioName+" failed")
+		assert.NotEmpty(t, actual.Title, "Scenario: "+scenario.scenarioName+" failed")
+		assert.Equal(t, scenario.expectedProblem.Code, actual.Code, "Scenario: "+scenario.scenarioName+" failed")
+		assert.Contains(t, actual.Detail, scenario.expectedProblem.Detail, "Scenario: "+scenario.scenarioName+" failed")
+		assert.Equal(t, scenario.expectedProblem.ConceptUUID, actual.ConceptUUID, "Scenario: "+scenario.scenarioName+" failed")
+		assert.Equal(t, scenario.expectedProblem.Authority, actual.Authority, "Scenario: "+scenario.scenarioName+" failed")
+		assert.Equal(t, scenario.expectedProblem.Value, actual.Value, "Scenario: "+scenario.scenarioName+" failed")
+	}
+}
+
+func TestErrorOfSeveralConceptsIsReportedAsTheFirstFailure(t *testing.T) {
+	writerErr := errors.New("Internal Error: Delete request to writer returned unexpected status: 503")
+	invalidTmeErr := &ConceptError{Code: ErrCodeInvalidTmeID, ConceptUUID: concordedTmeUUID, Authority: ConcordanceAuthorityTme, Value: "AbCd", Message: "Bad Request: Concordance id AbCd is not a valid TME Id"}
+	updateStatus, err := combineConceptResults([]conceptResult{
+		{status: ServiceUnavailable, conceptUUID: testUUID, err: writerErr},
+		{status: SyntacticallyIncorrect, conceptUUID: concordedTmeUUID, err: invalidTmeErr},
+	})
+
+	assert.Equal(t, ServiceUnavailable, updateStatus)
+	assert.Equal(t, ErrCodeWriterUnavailable, errorCode(err, updateStatus), "the code should be the one of the failure the status comes from")
+	p := newProblem(503, errorCode(err, updateStatus), err)
+	assert.Empty(t, p.ConceptUUID, "the concept should be the one of the failure the status comes from")
+	assert.Empty(t, p.Authority)
+	assert.Contains(t, p.Detail, "AbCd", "the detail should report every failure")
+}
@@ -0,0 +1,62 @@
+package smartlogic
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// factsetIDMatcher matches a FactSet permanent identifier: six letters or digits, a hyphen and the suffix of its type.
+var factsetIDMatcher = regexp.MustCompile(`^([0-9A-Z]{6})-([A-Z])$`)
+
+// factsetFormats names the types of FactSet permanent identifiers by their suffix.
+var factsetFormats = map[string]string{
+	"E": "entity",
+	"S": "security",
+	"R": "regional",
+	"L": "listing",
+}
+
+// defaultFactsetSuffixes are the suffixes of the FACTSET identifiers accepted unless the authority lists its own.
+var defaultFactsetSuffixes = []string{"E", "S", "R", "L"}
+
+// factsetValidator validates FactSet permanent identifiers whose suffix is allowed.
+type factsetValidator struct {
+	suffixes map[string]bool
+}
+
+func newFactsetValidator(suffixes []string) (factsetValidator, error) {
+	if len(suffixes) == 0 {
+		suffixes = defaultFactsetSuffixes
+	}
+	validator := factsetValidator{suffixes: map[string]bool{}}
+	for _, suffix := range suffixes {
+		if _, known := factsetFormats[suffix]; !known {
+			return factsetValidator{}, fmt.Errorf("unknown FACTSET suffix %q", suffix)
+		}
+		validator.suffixes[suffix] = true
+	}
+	return validator, nil
+}
+
+func (v factsetValidator) valid(factsetID string) bool {
+	return v.reason(factsetID) == ""
+}
+
+// reason explains why a FACTSET identifier is rejected, or is empty when it is valid.
+func (v factsetValidator) reason(factsetID string) string {
+	match := factsetIDMatcher.FindStringSubmatch(factsetID)
+	if match == nil {
+		return "expected six letters or digits, a hyphen and a suffix, e.g. 000D63-E"
+	}
+	format, known := factsetFormats[match[2]]
+	switch {
+	case !known:
+		return fmt.Sprintf("unknown suffix -%s", match[2])
+	case !v.suffixes[match[2]]:
+		return fmt.Sprintf("%s identifiers (-%s) are not allowed", format, match[2])
+	case match[2] == "E" && match[1][0] != '0':
+		return "entity identifiers start with 0"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,63 @@
+package smartlogic
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+var (
+	leiMatcher            = regexp.MustCompile(`^[0-9A-Z]{18}[0-9]{2}$`)
+	figiMatcher           = regexp.MustCompile(`^[B-DF-HJ-NP-TV-Z]{2}G[0-9B-DF-HJ-NP-TV-Z]{8}[0-9]$`)
+	companiesHouseMatcher = regexp.MustCompile(`^(?:[0-9]{2}|AC|CE|CS|FC|GE|GN|GS|IC|IP|LP|NA|NC|NF|NI|NL|NO|NP|NR|NV|NZ|OC|PC|R0|RC|RS|SA|SC|SE|SF|SG|SI|SL|SO|SP|SR|SZ|ZC)[0-9]{6}$`)
+
+	// figiReservedPrefixes are the prefixes a FIGI never starts with, so it can't be mistaken for an ISIN.
+	figiReservedPrefixes = map[string]bool{"BS": true, "BM": true, "GG": true, "GB": true, "GH": true, "KY": true, "VG": true}
+)
+
+// isValidLEI checks the shape of a Legal Entity Identifier and its ISO 7064 MOD 97-10 check digits: with its letters
+// replaced by 10 to 35, the identifier read as a number is 1 modulo 97.
+func isValidLEI(lei string) bool {
+	if !leiMatcher.MatchString(lei) {
+		return false
+	}
+	digits := make([]byte, 0, 2*len(lei))
+	for _, c := range lei {
+		digits = strconv.AppendInt(digits, alphanumericValue(c), 10)
+	}
+	number, _ := new(big.Int).SetString(string(digits), 10)
+	return new(big.Int).Mod(number, big.NewInt(97)).Int64() == 1
+}
+
+// isValidFIGI checks the shape of a Financial Instrument Global Identifier and its check digit: with its letters
+// replaced by 10 to 35 and every second value doubled, the digits of the values add up, with the check digit, to a
+// multiple of 10.
+func isValidFIGI(figi string) bool {
+	if !figiMatcher.MatchString(figi) || figiReservedPrefixes[figi[:2]] {
+		return false
+	}
+	sum := int64(0)
+	for i, c := range figi[:11] {
+		value := alphanumericValue(c)
+		if i%2 == 1 {
+			value *= 2
+		}
+		for ; value > 0; value /= 10 {
+			sum += value % 10
+		}
+	}
+	return (10-sum%10)%10 == int64(figi[11]-'0')
+}
+
+// isValidCompaniesHouseNumber checks a UK Companies House company number: eight digits, or the two letters of the
+// register of the company followed by six digits, e.g. SC for Scottish companies.
+func isValidCompaniesHouseNumber(number string) bool {
+	return companiesHouseMatcher.MatchString(number)
+}
+
+func alphanumericValue(c rune) int64 {
+	if c >= '0' && c <= '9' {
+		return int64(c - '0')
+	}
+	return int64(c-'A') + 10
+}
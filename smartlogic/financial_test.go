@@ -0,0 +1,125 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFinancialIdentifierValidation(t *testing.T) {
+	type testStruct struct {
+		testName       string
+		validate       func(string) bool
+		value          string
+		expectedResult bool
+	}
+
+	validLEI := testStruct{testName: "validLEI", validate: isValidLEI, value: "5493001KJTIIGC8Y1R12", expectedResult: true}
+	leiWithWrongCheckDigits := testStruct{testName: "leiWithWrongCheckDigits", validate: isValidLEI, value: "5493001KJTIIGC8Y1R13", expectedResult: false}
+	leiWithTransposedCharacters := testStruct{testName: "leiWithTransposedCharacters", validate: isValidLEI, value: "4593001KJTIIGC8Y1R12", expectedResult: false}
+	lowercaseLEI := testStruct{testName: "lowercaseLEI", validate: isValidLEI, value: "5493001kjtiigc8y1r12", expectedResult: false}
+	shortLEI := testStruct{testName: "shortLEI", validate: isValidLEI, value: "5493001KJTIIGC8Y1R1", expectedResult: false}
+	validFIGI := testStruct{testName: "validFIGI", validate: isValidFIGI, value: "BBG000BLNNH6", expectedResult: true}
+	figiWithWrongCheckDigit := testStruct{testName: "figiWithWrongCheckDigit", validate: isValidFIGI, value: "BBG000BLNNH7", expectedResult: false}
+	figiWithVowel := testStruct{testName: "figiWithVowel", validate: isValidFIGI, value: "BBG000BLANH6", expectedResult: false}
+	figiWithoutG := testStruct{testName: "figiWithoutG", validate: isValidFIGI, value: "BBX000BLNNH6", expectedResult: false}
+	figiWithISINPrefix := testStruct{testName: "figiWithISINPrefix", validate: isValidFIGI, value: "GBG000BLNNH6", expectedResult: false}
+	companyNumber := testStruct{testName: "companyNumber", validate: isValidCompaniesHouseNumber, value: "00445790", expectedResult: true}
+	scottishCompanyNumber := testStruct{testName: "scottishCompanyNumber", validate: isValidCompaniesHouseNumber, value: "SC123456", expectedResult: true}
+	unknownRegister := testStruct{testName: "unknownRegister", validate: isValidCompaniesHouseNumber, value: "XX123456", expectedResult: false}
+	shortCompanyNumber := testStruct{testName: "shortCompanyNumber", validate: isValidCompaniesHouseNumber, value: "445790", expectedResult: false}
+
+	testScenarios := []testStruct{
+		validLEI,
+		leiWithWrongCheckDigits,
+		leiWithTransposedCharacters,
+		lowercaseLEI,
+		shortLEI,
+		validFIGI,
+		figiWithWrongCheckDigit,
+		figiWithVowel,
+		figiWithoutG,
+		figiWithISINPrefix,
+		companyNumber,
+		scottishCompanyNumber,
+		unknownRegister,
+		shortCompanyNumber,
+	}
+
+	for _, scenario := range testScenarios {
+		assert.Equal(t, scenario.expectedResult, scenario.validate(scenario.value), "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestFinancialIdentifierConcordances(t *testing.T) {
+	lei := "5493001KJTIIGC8Y1R12"
+	figi := "BBG000BLNNH6"
+	companyNumber := "00445790"
+
+	type testStruct struct {
+		testName             string
+		conceptUUID          string
+		identifiers          string
+		expectedConcordedIDs []ConcordedID
+		expectedCode         ErrorCode
+	}
+
+	organisation := testStruct{
+		testName:    "organisation",
+		conceptUUID: "20db1bd6-59f9-4404-adb5-3165a448f8b0",
+		identifiers: `"http://www.ft.com/ontology/leiIdentifier": "` + lei + `", "http://www.ft.com/ontology/figiIdentifier": [{"@value": "` + figi + `"}], "http://www.ft.com/ontology/companiesHouseIdentifier": "` + companyNumber + `"`,
+		expectedConcordedIDs: []ConcordedID{
+			{Authority: ConcordanceAuthorityCompaniesHouse, AuthorityValue: companyNumber, UUID: convertToUUID(companyNumber)},
+			{Authority: ConcordanceAuthorityFIGI, AuthorityValue: figi, UUID: convertToUUID(figi)},
+			{Authority: ConcordanceAuthorityLEI, AuthorityValue: lei, UUID: convertToUUID(lei)},
+		},
+	}
+	invalidLEI := testStruct{
+		testName:     "invalidLEI",
+		conceptUUID:  "20db1bd6-59f9-4404-adb5-3165a448f8b0",
+		identifiers:  `"http://www.ft.com/ontology/leiIdentifier": "5493001KJTIIGC8Y1R13"`,
+		expectedCode: ErrCodeInvalidLEI,
+	}
+	invalidFIGI := testStruct{
+		testName:     "invalidFIGI",
+		conceptUUID:  "20db1bd6-59f9-4404-adb5-3165a448f8b0",
+		identifiers:  `"http://www.ft.com/ontology/figiIdentifier": "BBG000BLNNH7"`,
+		expectedCode: ErrCodeInvalidFIGI,
+	}
+	invalidCompanyNumber := testStruct{
+		testName:     "invalidCompanyNumber",
+		conceptUUID:  "20db1bd6-59f9-4404-adb5-3165a448f8b0",
+		identifiers:  `"http://www.ft.com/ontology/companiesHouseIdentifier": "445790"`,
+		expectedCode: ErrCodeInvalidCompaniesHouseID,
+	}
+	duplicateLEI := testStruct{
+		testName:     "duplicateLEI",
+		conceptUUID:  "20db1bd6-59f9-4404-adb5-3165a448f8b0",
+		identifiers:  `"http://www.ft.com/ontology/leiIdentifier": ["` + lei + `", "` + lei + `"]`,
+		expectedCode: ErrCodeDuplicateIdentifier,
+	}
+	leiUUIDEqualConceptUUID := testStruct{
+		testName:     "leiUUIDEqualConceptUUID",
+		conceptUUID:  convertToUUID(lei),
+		identifiers:  `"http://www.ft.com/ontology/leiIdentifier": "` + lei + `"`,
+		expectedCode: ErrCodeUUIDCollision,
+	}
+
+	testScenarios := []testStruct{organisation, invalidLEI, invalidFIGI, invalidCompanyNumber, duplicateLEI, leiUUIDEqualConceptUUID}
+
+	transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger())
+	for _, scenario := range testScenarios {
+		payload := `{"@id": "http://www.ft.com/thing/` + scenario.conceptUUID + `", "@type": ["http://www.ft.com/ontology/organisation/Organisation"], ` + scenario.identifiers + `}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_financial")
+		if scenario.expectedCode != "" {
+			assert.Equal(t, SyntacticallyIncorrect, updateStatus, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedCode, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
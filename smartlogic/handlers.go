@@ -2,9 +2,10 @@ package smartlogic
 
 import (
 	"encoding/json"
-	"net/http"
-
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/kafka-client-go/v4"
@@ -13,34 +14,124 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const (
+	transactionIDHeader = "X-Request-Id"
+	// forceSendHeader makes a Kafka message send its concordance records even when they are unchanged since they were
+	// last sent, like the force query parameter of /transform/send
+	forceSendHeader = "X-Force-Send"
+)
+
 type messageConsumer interface {
 	ConnectivityCheck() error
 	MonitorCheck() error
 }
 
+type messageProducer interface {
+	SendMessage(message kafka.FTMessage) error
+	ConnectivityCheck() error
+}
+
 type ConcordanceTransformerHandler struct {
-	transformer TransformerService
-	consumer    messageConsumer
-	log         *logger.UPPLogger
+	transformer        TransformerService
+	consumer           messageConsumer
+	deadLetterProducer messageProducer
+	concurrency        int
+	coalesceWindow     time.Duration
+	workers            *conceptWorkerPool
+	coalescer          *conceptCoalescer
+	log                *logger.UPPLogger
+}
+
+// HandlerOption configures optional behaviour of the ConcordanceTransformerHandler.
+type HandlerOption func(h *ConcordanceTransformerHandler)
+
+// WithDeadLetterProducer makes the handler publish Kafka messages which could not be processed to a dead letter topic.
+func WithDeadLetterProducer(producer messageProducer) HandlerOption {
+	return func(h *ConcordanceTransformerHandler) {
+		h.deadLetterProducer = producer
+	}
 }
 
-func NewHandler(transformer TransformerService, consumer messageConsumer, log *logger.UPPLogger) ConcordanceTransformerHandler {
-	return ConcordanceTransformerHandler{
+// WithConcurrency makes the handler process Kafka messages for different concepts in parallel on the given
+// number of workers. Messages for the same concept are still processed in the order they were consumed.
+func WithConcurrency(concurrency int) HandlerOption {
+	return func(h *ConcordanceTransformerHandler) {
+		h.concurrency = concurrency
+	}
+}
+
+// WithCoalesceWindow makes the handler wait for the given window after a Kafka message for a concept arrives and
+// only process the latest message received for that concept within the window.
+func WithCoalesceWindow(window time.Duration) HandlerOption {
+	return func(h *ConcordanceTransformerHandler) {
+		h.coalesceWindow = window
+	}
+}
+
+func NewHandler(transformer TransformerService, consumer messageConsumer, log *logger.UPPLogger, opts ...HandlerOption) ConcordanceTransformerHandler {
+	h := ConcordanceTransformerHandler{
 		transformer: transformer,
 		consumer:    consumer,
+		concurrency: 1,
 		log:         log,
 	}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	// coalesced messages are released from timers, so they need the workers to stay ordered per concept
+	if h.concurrency > 1 || h.coalesceWindow > 0 {
+		h.workers = newConceptWorkerPool(max(h.concurrency, 1), h.processKafkaMessage)
+	}
+	if h.coalesceWindow > 0 {
+		h.coalescer = newConceptCoalescer(h.coalesceWindow, h.workers.enqueue, log)
+	}
+	return h
+}
+
+// Close releases the Kafka messages waiting in a coalescing window and waits for the messages already handed to
+// the workers to be processed.
+func (h *ConcordanceTransformerHandler) Close() {
+	if h.coalescer != nil {
+		h.coalescer.close()
+	}
+	if h.workers != nil {
+		h.workers.close()
+	}
 }
 
 func (h *ConcordanceTransformerHandler) ProcessKafkaMessage(msg kafka.FTMessage) {
-	var tid string
-	if msg.Headers["X-Request-Id"] == "" {
-		tid = transactionidutils.NewTransactionID()
-	} else {
-		tid = msg.Headers["X-Request-Id"]
+	if msg.Headers[transactionIDHeader] == "" {
+		headers := map[string]string{transactionIDHeader: transactionidutils.NewTransactionID()}
+		for k, v := range msg.Headers {
+			if k != transactionIDHeader {
+				headers[k] = v
+			}
+		}
+		msg.Headers = headers
+	}
+
+	switch {
+	case h.coalescer != nil:
+		h.coalescer.submit(conceptKeys(msg.Body), msg)
+	case h.workers != nil:
+		h.workers.dispatch(conceptKeys(msg.Body), msg)
+	default:
+		h.processKafkaMessage(msg)
+	}
+}
+
+func (h *ConcordanceTransformerHandler) processKafkaMessage(msg kafka.FTMessage) {
+	tid := msg.Headers[transactionIDHeader]
+
+	updateStatus, err := h.transformer.handleConcordanceEvent(msg.Body, tid, forced(msg))
+	if err != nil {
+		h.sendToDeadLetterTopic(msg, tid, updateStatus, err)
 	}
+}
 
-	_ = h.transformer.handleConcordanceEvent(msg.Body, tid)
+func forced(msg kafka.FTMessage) bool {
+	force, _ := strconv.ParseBool(msg.Headers[forceSendHeader])
+	return force
 }
 
 func (h *ConcordanceTransformerHandler) RegisterHandlers(router *mux.Router) {
@@ -65,24 +156,35 @@ func (h *ConcordanceTransformerHandler) TransformHandler(rw http.ResponseWriter,
 
 	if err != nil {
 		h.log.WithError(err).WithField("transaction_id", tid).Error("Error whilst processing request body")
-		rw.WriteHeader(http.StatusBadRequest)
-		_, err := rw.Write([]byte("{\"message\":\"Error whilst processing request body: " + err.Error() + "\"}"))
-		if err != nil {
-			h.log.WithError(err).Info("Failed to send response from TransformHandler")
-		}
+		writeProblem(rw, newProblem(http.StatusBadRequest, ErrCodeInvalidJSON, fmt.Errorf("Error whilst processing request body: %w", err)))
 		return
 	}
 
 	h.log.WithField("transaction_id", tid).Debug("Processing concordance transformation")
-	updateStatus, conceptUUID, uppConcordance, err := convertToUppConcordance(smartLogicConcept, tid, h.log)
+	results, err := h.transformer.convertToUppConcordances(smartLogicConcept, tid)
+	if err != nil {
+		writeResponse(rw, SemanticallyIncorrect, err)
+		return
+	}
+	if len(results) > 1 {
+		h.writeConceptResults(rw, tid, results, true)
+		return
+	}
 
+	updateStatus, conceptUUID, uppConcordance, err := results[0].status, results[0].conceptUUID, results[0].uppConcordance, results[0].err
 	if err != nil {
 		writeResponse(rw, updateStatus, err)
 		return
 	}
 
-	if err := json.NewEncoder(rw).Encode(uppConcordance); err != nil {
+	body, err := encodeTransformedConcordance(withTmeTaxonomies(uppConcordance))
+	if err != nil {
 		h.log.WithError(err).Error("Could not encode transformed concordance response")
+		writeResponse(rw, InternalError, err)
+		return
+	}
+	if _, err = rw.Write(append(body, '\n')); err != nil {
+		h.log.WithError(err).Error("Could not write transformed concordance response")
 		return
 	}
 	h.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID, "status": http.StatusOK}).Info("Smartlogic payload successfully transformed")
@@ -98,39 +200,46 @@ func (h *ConcordanceTransformerHandler) SendHandler(rw http.ResponseWriter, req
 
 	if err != nil {
 		h.log.WithError(err).WithField("transaction_id", tid).Error("Error whilst processing request body")
-		rw.WriteHeader(http.StatusBadRequest)
-		_, err := rw.Write([]byte("{\"message\":\"Error whilst processing request body:" + err.Error() + "\"}"))
-		if err != nil {
-			h.log.WithError(err).Errorf("Failed to send response from SendHandler")
-		}
+		writeProblem(rw, newProblem(http.StatusBadRequest, ErrCodeInvalidJSON, fmt.Errorf("Error whilst processing request body: %w", err)))
 		return
 	}
 
 	h.log.WithField("transaction_id", tid).Debug("Processing concordance transformation")
-	updateStatus, conceptUUID, uppConcordance, err := convertToUppConcordance(smartLogicConcept, tid, h.log)
-
+	results, err := h.transformer.convertToUppConcordances(smartLogicConcept, tid)
 	if err != nil {
-		writeResponse(rw, updateStatus, err)
+		writeResponse(rw, SemanticallyIncorrect, err)
+		return
 	}
 
-	updateStatus, err = h.transformer.makeRelevantRequest(conceptUUID, uppConcordance, tid)
+	force, _ := strconv.ParseBool(req.URL.Query().Get("force"))
+	for i, result := range results {
+		if result.err != nil {
+			continue
+		}
+		results[i].status, results[i].err = h.transformer.sendIfModified(result.conceptUUID, result.uppConcordance, tid, force, h.transformer.makeRelevantRequest)
+		if results[i].err == nil {
+			h.transformer.knownMerges.record(result.conceptUUID, result.uppConcordance)
+		}
+	}
+	if len(results) > 1 {
+		h.writeConceptResults(rw, tid, results, false)
+		return
+	}
 
+	updateStatus, conceptUUID, err := results[0].status, results[0].conceptUUID, results[0].err
 	if err != nil {
 		writeResponse(rw, updateStatus, err)
 		return
 	}
 
-	var message string
-	switch updateStatus {
-	case ValidConcept:
-		message = "Concordance record forwarded to writer"
-	case NoContent:
-		message = "Concordance record successfully deleted"
-	case NotFound:
-		message = "Concordance record not found"
+	message := sendMessage(updateStatus)
+	body, err := json.Marshal(sendResponse{Message: message, Duplicates: results[0].uppConcordance.Duplicates})
+	if err != nil {
+		h.log.WithError(err).Error("Could not encode send response")
+		writeResponse(rw, InternalError, err)
+		return
 	}
-
-	if _, err = rw.Write([]byte("{\"message\":\"" + message + "\"}")); err != nil {
+	if _, err = rw.Write(body); err != nil {
 		h.log.
 			WithError(err).
 			WithField("response_message", message).
@@ -145,27 +254,117 @@ func (h *ConcordanceTransformerHandler) SendHandler(rw http.ResponseWriter, req
 		Info(message)
 }
 
-func writeResponse(rw http.ResponseWriter, updateStatus status, err error) {
+// sendResponse is the outcome of sending a single concept, with the identifiers dropped as duplicates.
+type sendResponse struct {
+	Message    string                `json:"message"`
+	Duplicates []DuplicateIdentifier `json:"duplicates,omitempty"`
+}
+
+func sendMessage(updateStatus status) string {
 	switch updateStatus {
-	case SyntacticallyIncorrect:
-		writeJSONError(rw, err.Error(), http.StatusBadRequest)
+	case ValidConcept:
+		return "Concordance record forwarded to writer"
+	case NoContent:
+		return "Concordance record successfully deleted"
+	case NotFound:
+		return "Concordance record not found"
+	case NotModified:
+		return "Concordance record not modified since it was last sent; use force=true to send it anyway"
+	default:
+		return ""
+	}
+}
+
+type conceptResponse struct {
+	ConceptUUID    string                `json:"uuid,omitempty"`
+	Status         string                `json:"status"`
+	UppConcordance json.RawMessage       `json:"concordance,omitempty"`
+	Message        string                `json:"message,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	Code           ErrorCode             `json:"code,omitempty"`
+	Errors         []ValidationIssue     `json:"errors,omitempty"`
+	Duplicates     []DuplicateIdentifier `json:"duplicates,omitempty"`
+}
+
+type conceptsResponse struct {
+	Concepts []conceptResponse `json:"concepts"`
+}
+
+// writeConceptResults reports the outcome of every concept in a multi-concept payload. The response is a 200 when
+// every concept succeeded, a 207 when only some did, and otherwise carries the status of the first failure.
+func (h *ConcordanceTransformerHandler) writeConceptResults(rw http.ResponseWriter, tid string, results []conceptResult, includeConcordance bool) {
+	response := conceptsResponse{Concepts: make([]conceptResponse, 0, len(results))}
+	statusCode := http.StatusOK
+	failures := 0
+	for _, result := range results {
+		conceptResp := conceptResponse{ConceptUUID: result.conceptUUID, Status: result.status.String()}
+		switch {
+		case result.err != nil:
+			conceptResp.Error = result.err.Error()
+			conceptResp.Code = errorCode(result.err, result.status)
+			conceptResp.Errors = validationIssues(result.err)
+			if failures == 0 {
+				statusCode = httpStatusCode(result.status)
+			}
+			failures++
+		case includeConcordance:
+			body, err := encodeTransformedConcordance(withTmeTaxonomies(result.uppConcordance))
+			if err != nil {
+				h.log.WithError(err).Error("Could not encode transformed concordance response")
+				writeResponse(rw, InternalError, err)
+				return
+			}
+			conceptResp.UppConcordance = body
+		default:
+			conceptResp.Message = sendMessage(result.status)
+			conceptResp.Duplicates = result.uppConcordance.Duplicates
+		}
+		response.Concepts = append(response.Concepts, conceptResp)
+	}
+	if failures > 0 && failures < len(results) {
+		statusCode = http.StatusMultiStatus
+	}
+
+	rw.WriteHeader(statusCode)
+	encoder := json.NewEncoder(rw)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(response); err != nil {
+		h.log.WithError(err).Error("Could not encode multi-concept response")
 		return
+	}
+	h.log.WithFields(map[string]interface{}{"transaction_id": tid, "status": statusCode, "concepts": len(results), "failures": failures}).Info("Smartlogic multi-concept payload processed")
+}
+
+// withTmeTaxonomies returns a copy of the concordance record where TME concordances hold the taxonomy decoded from
+// their identifier.
+func withTmeTaxonomies(uppConcordance UppConcordance) UppConcordance {
+	concordedIDs := make([]ConcordedID, len(uppConcordance.ConcordedIds))
+	for i, concordedID := range uppConcordance.ConcordedIds {
+		if concordedID.Authority == ConcordanceAuthorityTme {
+			concordedID.Taxonomy = tmeTaxonomy(concordedID.AuthorityValue)
+		}
+		concordedIDs[i] = concordedID
+	}
+	uppConcordance.ConcordedIds = concordedIDs
+	return uppConcordance
+}
+
+func httpStatusCode(updateStatus status) int {
+	switch updateStatus {
+	case SyntacticallyIncorrect:
+		return http.StatusBadRequest
 	case SemanticallyIncorrect:
-		writeJSONError(rw, err.Error(), http.StatusUnprocessableEntity)
-		return
+		return http.StatusUnprocessableEntity
 	case ServiceUnavailable:
-		writeJSONError(rw, err.Error(), http.StatusServiceUnavailable)
-		return
-	case InternalError:
-		writeJSONError(rw, err.Error(), http.StatusInternalServerError)
-		return
+		return http.StatusServiceUnavailable
 	default:
-		writeJSONError(rw, "Unknown error", http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError
 	}
 }
 
-func writeJSONError(w http.ResponseWriter, errorMsg string, statusCode int) {
-	w.WriteHeader(statusCode)
-	_, _ = w.Write([]byte(fmt.Sprintf("{\"message\": \"%s\"}", errorMsg)))
+// writeResponse reports the failure of a concept as problem details, with the code of the error so clients don't
+// have to parse its message.
+func writeResponse(rw http.ResponseWriter, updateStatus status, err error) {
+	statusCode := httpStatusCode(updateStatus)
+	writeProblem(rw, newProblem(statusCode, errorCode(err, updateStatus), err))
 }
@@ -2,14 +2,18 @@ package smartlogic
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
@@ -41,10 +45,10 @@ func TestTransformAndSendHandlers(t *testing.T) {
 		expectedResult     string
 	}
 
-	transformUnprocessibleEntityError := testStruct{scenarioName: "transform_unprocessibleEntityError", filePath: "../resources/multipleGraphsInList.json", endpoint: "/transform", expectedStatusCode: 422, expectedResult: "invalid Request Json: More than 1 concept in smartlogic concept payload which is currently not supported"}
+	transformUnprocessibleEntityError := testStruct{scenarioName: "transform_unprocessibleEntityError", filePath: "../resources/missingIdField.json", endpoint: "/transform", expectedStatusCode: 422, expectedResult: "invalid Request Json: Missing/invalid @graph field"}
 	transformConvertingToConcordedJSONError := testStruct{scenarioName: "transform_convertingToConcordedJsonError", filePath: "../resources/invalidTmeId.json", endpoint: "/transform", expectedStatusCode: 400, expectedResult: "is not a valid TME Id"}
 	transformDuplicateTMEIDsError := testStruct{scenarioName: "transform_duplicateTmeIdsError", filePath: "../resources/duplicateTmeIds.json", endpoint: "/transform", expectedStatusCode: 400, expectedResult: "contains duplicate TME id values"}
-	sendUnprocessibleEntityError := testStruct{scenarioName: "send_unprocessibleEntityError", filePath: "../resources/multipleGraphsInList.json", endpoint: "/transform/send", expectedStatusCode: 422, expectedResult: "invalid Request Json: More than 1 concept in smartlogic concept payload which is currently not supported"}
+	sendUnprocessibleEntityError := testStruct{scenarioName: "send_unprocessibleEntityError", filePath: "../resources/missingIdField.json", endpoint: "/transform/send", expectedStatusCode: 422, expectedResult: "invalid Request Json: Missing/invalid @graph field"}
 	sendConvertingToConcordedJSONError := testStruct{scenarioName: "send_convertingToConcordedJsonError", filePath: "../resources/invalidTmeId.json", endpoint: "/transform/send", expectedStatusCode: 400, expectedResult: "is not a valid TME Id"}
 	sendConvertsAndFailsForwardToRW := testStruct{scenarioName: "send_convertsAndFailsForwardToRw", filePath: "../resources/noTmeIds.json", endpoint: "/transform/send", expectedStatusCode: 500, expectedResult: "Internal Error: Delete request to writer returned unexpected status:"}
 
@@ -67,21 +71,21 @@ func TestTransformAndSendHandlers(t *testing.T) {
 		filePath:           "../resources/multipleTmeIds.json",
 		endpoint:           "/transform",
 		expectedStatusCode: 200,
-		expectedResult:     `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","concordances":[{"authority":"TME","authorityValue":"AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789","uuid":"e9f4525a-401f-3b23-a68e-e48f314cdce6"},{"authority":"TME","authorityValue":"ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321","uuid":"83f63c7e-1641-3c7b-81e4-378ae3c6c2ad"},{"authority":"TME","authorityValue":"abcdefghijklmnopqrstuvwxyz-0123456789","uuid":"e4bc4ac2-0637-3a27-86b1-9589fca6bf2c"},{"authority":"TME","authorityValue":"ABCDEFGHIJKLMNOPQRSTUVWXYZ-0987654321","uuid":"e574b21d-9abc-3d82-a6c0-3e08c85181bf"}]}`,
+		expectedResult:     `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","conceptType":"Brand","concordances":[{"authority":"TME","authorityValue":"ABCDEFGHIJKLMNOPQRSTUVWXYZ-0987654321","uuid":"e574b21d-9abc-3d82-a6c0-3e08c85181bf"},{"authority":"TME","authorityValue":"AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789","uuid":"e9f4525a-401f-3b23-a68e-e48f314cdce6"},{"authority":"TME","authorityValue":"ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321","uuid":"83f63c7e-1641-3c7b-81e4-378ae3c6c2ad"},{"authority":"TME","authorityValue":"abcdefghijklmnopqrstuvwxyz-0123456789","uuid":"e4bc4ac2-0637-3a27-86b1-9589fca6bf2c"}]}`,
 	}
 	transformConvertsFactsETSAndReturnsPayload := testStruct{
 		scenarioName:       "transform_convertsFactsetsAndReturnsPayload",
 		filePath:           "../resources/multipleFactsetIds.json",
 		endpoint:           "/transform",
 		expectedStatusCode: 200,
-		expectedResult:     `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","concordances":[{"authority":"FACTSET","authorityValue":"000D63-E","uuid":"8d3aba95-02d9-3802-afc0-b99bb9b1139e"},{"authority":"FACTSET","authorityValue":"023456-E","uuid":"3bc0ab41-c01f-3a0b-aa78-c76438080b52"},{"authority":"FACTSET","authorityValue":"023411-E","uuid":"f777c5af-e0b2-34dc-9102-e346ca2d27aa"}]}`,
+		expectedResult:     `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","conceptType":"Brand","concordances":[{"authority":"FACTSET","authorityValue":"000D63-E","uuid":"8d3aba95-02d9-3802-afc0-b99bb9b1139e"},{"authority":"FACTSET","authorityValue":"023411-E","uuid":"f777c5af-e0b2-34dc-9102-e346ca2d27aa"},{"authority":"FACTSET","authorityValue":"023456-E","uuid":"3bc0ab41-c01f-3a0b-aa78-c76438080b52"}]}`,
 	}
 	transformConvertsTMEAndFactSetsAndReturnsPayload := testStruct{
 		scenarioName:       "transform_convertsTmeAndFactsetsAndReturnsPayload",
 		filePath:           "../resources/multipleTmeAndFactsetIds.json",
 		endpoint:           "/transform",
 		expectedStatusCode: 200,
-		expectedResult:     `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","concordances":[{"authority":"TME","authorityValue":"AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789","uuid":"e9f4525a-401f-3b23-a68e-e48f314cdce6"},{"authority":"TME","authorityValue":"ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321","uuid":"83f63c7e-1641-3c7b-81e4-378ae3c6c2ad"},{"authority":"TME","authorityValue":"abcdefghijklmnopqrstuvwxyz-0123456789","uuid":"e4bc4ac2-0637-3a27-86b1-9589fca6bf2c"},{"authority":"FACTSET","authorityValue":"000D63-E","uuid":"8d3aba95-02d9-3802-afc0-b99bb9b1139e"},{"authority":"FACTSET","authorityValue":"023456-E","uuid":"3bc0ab41-c01f-3a0b-aa78-c76438080b52"},{"authority":"FACTSET","authorityValue":"023411-E","uuid":"f777c5af-e0b2-34dc-9102-e346ca2d27aa"}]}`,
+		expectedResult:     `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","conceptType":"Brand","concordances":[{"authority":"FACTSET","authorityValue":"000D63-E","uuid":"8d3aba95-02d9-3802-afc0-b99bb9b1139e"},{"authority":"FACTSET","authorityValue":"023411-E","uuid":"f777c5af-e0b2-34dc-9102-e346ca2d27aa"},{"authority":"FACTSET","authorityValue":"023456-E","uuid":"3bc0ab41-c01f-3a0b-aa78-c76438080b52"},{"authority":"TME","authorityValue":"AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789","uuid":"e9f4525a-401f-3b23-a68e-e48f314cdce6"},{"authority":"TME","authorityValue":"ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321","uuid":"83f63c7e-1641-3c7b-81e4-378ae3c6c2ad"},{"authority":"TME","authorityValue":"abcdefghijklmnopqrstuvwxyz-0123456789","uuid":"e4bc4ac2-0637-3a27-86b1-9589fca6bf2c"}]}`,
 	}
 	sendConvertsAndForwardsPayloadWithConcordance := testStruct{
 		scenarioName:       "send_convertsAndForwardsPayloadWithConcordance",
@@ -110,7 +114,11 @@ func TestTransformAndSendHandlers(t *testing.T) {
 		rec := httptest.NewRecorder()
 		r.ServeHTTP(rec, newRequest("POST", scenario.endpoint, readFile(t, scenario.filePath)))
 		assert.Equal(t, scenario.expectedStatusCode, rec.Code, scenario.scenarioName)
-		assert.Equal(t, rec.Header()["Content-Type"], []string{"application/json"}, scenario.scenarioName)
+		expectedContentType := ExpectedContentType
+		if scenario.expectedStatusCode >= 400 {
+			expectedContentType = problemContentType
+		}
+		assert.Equal(t, []string{expectedContentType}, rec.Header()["Content-Type"], scenario.scenarioName)
 		assert.Contains(t, rec.Body.String(), scenario.expectedResult, "Failed scenario: "+scenario.scenarioName)
 	}
 }
@@ -153,7 +161,7 @@ func TestSendHandlerUnavailableWriter(t *testing.T) {
 	rec := httptest.NewRecorder()
 	r.ServeHTTP(rec, newRequest("POST", "/transform/send", readFile(t, "../resources/noTmeIds.json")))
 	assert.Equal(t, 500, rec.Code, "Unexpected status code")
-	assert.Equal(t, rec.Header()["Content-Type"], []string{"application/json"}, "Unexpected Content-Type")
+	assert.Equal(t, []string{problemContentType}, rec.Header()["Content-Type"], "Unexpected Content-Type")
 	assert.Contains(t, rec.Body.String(), "Delete request to writer returned unexpected status: 503", "Request had unexpected result")
 }
 
@@ -167,10 +175,66 @@ func TestSendHandlerWriteReturnsError(t *testing.T) {
 	rec := httptest.NewRecorder()
 	r.ServeHTTP(rec, newRequest("POST", "/transform/send", readFile(t, "../resources/noTmeIds.json")))
 	assert.Equal(t, 503, rec.Code, "Unexpected status code")
-	assert.Equal(t, rec.Header()["Content-Type"], []string{"application/json"}, "Unexpected Content-Type")
+	assert.Equal(t, []string{problemContentType}, rec.Header()["Content-Type"], "Unexpected Content-Type")
 	assert.Contains(t, rec.Body.String(), "delete request to writer returned unexpected status: 503", "Request had unexpected result")
 }
 
+func TestProcessKafkaMessageDeadLetter(t *testing.T) {
+	type testStruct struct {
+		scenarioName          string
+		filePath              string
+		statusCode            int
+		expectedDeadLetter    bool
+		expectedFailureStatus string
+		expectedError         string
+	}
+
+	successfulWrite := testStruct{scenarioName: "successfulWrite", filePath: "../resources/multipleTmeIds.json", statusCode: 200, expectedDeadLetter: false}
+	transformationFailure := testStruct{scenarioName: "transformationFailure", filePath: "../resources/invalidTmeId.json", statusCode: 200, expectedDeadLetter: true, expectedFailureStatus: "SyntacticallyIncorrect", expectedError: "is not a valid TME Id"}
+	writerFailure := testStruct{scenarioName: "writerFailure", filePath: "../resources/multipleTmeIds.json", statusCode: 503, expectedDeadLetter: true, expectedFailureStatus: "InternalError", expectedError: "Get request to writer returned unexpected status: 503"}
+
+	testScenarios := []testStruct{successfulWrite, transformationFailure, writerFailure}
+
+	for _, scenario := range testScenarios {
+		mockClient := mockHTTPClient{resp: "", statusCode: scenario.statusCode}
+		producer := &mockProducer{}
+		transformer := NewTransformerService(TOPIC, WriterAddress, &mockClient, createLogger())
+		h := NewHandler(transformer, mockConsumer{}, createLogger(), WithDeadLetterProducer(producer))
+
+		body := readFile(t, scenario.filePath)
+		headers := map[string]string{"X-Request-Id": "tid_dlq", "Origin-System-Id": "smartlogic"}
+		h.ProcessKafkaMessage(kafka.NewFTMessage(headers, body))
+
+		if !scenario.expectedDeadLetter {
+			assert.Empty(t, producer.messages, "Scenario: "+scenario.scenarioName)
+			continue
+		}
+		if !assert.Len(t, producer.messages, 1, "Scenario: "+scenario.scenarioName) {
+			continue
+		}
+		sent := producer.messages[0]
+		assert.Equal(t, "tid_dlq", sent.Headers["X-Request-Id"], "Scenario: "+scenario.scenarioName)
+
+		var dlqMsg deadLetterMessage
+		assert.NoError(t, json.Unmarshal([]byte(sent.Body), &dlqMsg), "Scenario: "+scenario.scenarioName)
+		assert.Equal(t, "tid_dlq", dlqMsg.TransactionID, "Scenario: "+scenario.scenarioName)
+		assert.Equal(t, scenario.expectedFailureStatus, dlqMsg.Status, "Scenario: "+scenario.scenarioName)
+		assert.Contains(t, dlqMsg.Error, scenario.expectedError, "Scenario: "+scenario.scenarioName)
+		assert.Equal(t, headers, dlqMsg.Headers, "Scenario: "+scenario.scenarioName)
+		assert.Equal(t, body, dlqMsg.Body, "Scenario: "+scenario.scenarioName)
+	}
+}
+
+func TestProcessKafkaMessageWithoutDeadLetterProducer(t *testing.T) {
+	mockClient := mockHTTPClient{resp: "", statusCode: 503}
+	transformer := NewTransformerService(TOPIC, WriterAddress, &mockClient, createLogger())
+	h := NewHandler(transformer, mockConsumer{}, createLogger())
+
+	assert.NotPanics(t, func() {
+		h.ProcessKafkaMessage(kafka.NewFTMessage(map[string]string{}, readFile(t, "../resources/invalidTmeId.json")))
+	})
+}
+
 func (c mockHTTPClient) Do(_ *http.Request) (resp *http.Response, err error) {
 	cb := ioutil.NopCloser(bytes.NewReader([]byte(c.resp)))
 	return &http.Response{Body: cb, StatusCode: c.statusCode}, c.err
@@ -192,6 +256,32 @@ func (mc mockConsumer) ConnectivityCheck() error {
 	return mc.err
 }
 
+type mockProducer struct {
+	messages []kafka.FTMessage
+	keys     []string
+	err      error
+}
+
+func (mp *mockProducer) SendMessage(message kafka.FTMessage) error {
+	if mp.err != nil {
+		return mp.err
+	}
+	mp.messages = append(mp.messages, message)
+	return nil
+}
+
+func (mp *mockProducer) SendKeyedMessage(key string, message kafka.FTMessage) error {
+	if err := mp.SendMessage(message); err != nil {
+		return err
+	}
+	mp.keys = append(mp.keys, key)
+	return nil
+}
+
+func (mp *mockProducer) ConnectivityCheck() error {
+	return mp.err
+}
+
 func newRequest(method, url string, body string) *http.Request {
 	var payload io.Reader
 	if body != "" {
@@ -206,3 +296,127 @@ func newRequest(method, url string, body string) *http.Request {
 	}
 	return req
 }
+
+func TestHandlersWithMultipleConcepts(t *testing.T) {
+	invalidTmeIssue := ValidationIssue{
+		Code:      ErrCodeInvalidTmeID,
+		Authority: ConcordanceAuthorityTme,
+		Value:     "AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789",
+		Reason:    "Bad Request: Concordance id AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789 is not a valid TME Id",
+		Path:      "$['@graph'][1]['http://www.ft.com/ontology/TMEIdentifier'][0]['@value']",
+	}
+
+	type testStruct struct {
+		scenarioName       string
+		filePath           string
+		endpoint           string
+		expectedStatusCode int
+		expectedConcepts   []conceptResponse
+	}
+
+	transformAllConcepts := testStruct{
+		scenarioName:       "transform_allConcepts",
+		filePath:           "../resources/multipleConcepts.json",
+		endpoint:           "/transform",
+		expectedStatusCode: 200,
+		expectedConcepts: []conceptResponse{
+			{ConceptUUID: testUUID, Status: "ValidConcept", UppConcordance: transformedConcordance(t, UppConcordance{Authority: ConcordanceAuthoritySmartlogic, ConceptUUID: testUUID, ConceptType: "Brand", ConcordedIds: []ConcordedID{{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"}}})},
+			{ConceptUUID: "95f00e25-9a5f-45ec-8ad8-5607d021c74b", Status: "ValidConcept", UppConcordance: transformedConcordance(t, UppConcordance{Authority: ConcordanceAuthoritySmartlogic, ConceptUUID: "95f00e25-9a5f-45ec-8ad8-5607d021c74b", ConceptType: "Brand", ConcordedIds: []ConcordedID{{Authority: ConcordanceAuthorityTme, AuthorityValue: "ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321", UUID: "83f63c7e-1641-3c7b-81e4-378ae3c6c2ad"}}})},
+		},
+	}
+	transformPartiallyInvalid := testStruct{
+		scenarioName:       "transform_partiallyInvalid",
+		filePath:           "../resources/multipleConceptsPartiallyInvalid.json",
+		endpoint:           "/transform",
+		expectedStatusCode: 207,
+		expectedConcepts: []conceptResponse{
+			{ConceptUUID: testUUID, Status: "ValidConcept", UppConcordance: transformedConcordance(t, UppConcordance{Authority: ConcordanceAuthoritySmartlogic, ConceptUUID: testUUID, ConceptType: "Brand", ConcordedIds: []ConcordedID{{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"}}})},
+			{ConceptUUID: "95f00e25-9a5f-45ec-8ad8-5607d021c74b", Status: "SyntacticallyIncorrect", Error: "Bad Request: Concordance id AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789 is not a valid TME Id", Code: ErrCodeInvalidTmeID, Errors: []ValidationIssue{invalidTmeIssue}},
+		},
+	}
+	sendPartiallyInvalid := testStruct{
+		scenarioName:       "send_partiallyInvalid",
+		filePath:           "../resources/multipleConceptsPartiallyInvalid.json",
+		endpoint:           "/transform/send",
+		expectedStatusCode: 207,
+		expectedConcepts: []conceptResponse{
+			{ConceptUUID: testUUID, Status: "ValidConcept", Message: "Concordance record forwarded to writer"},
+			{ConceptUUID: "95f00e25-9a5f-45ec-8ad8-5607d021c74b", Status: "SyntacticallyIncorrect", Error: "Bad Request: Concordance id AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789 is not a valid TME Id", Code: ErrCodeInvalidTmeID, Errors: []ValidationIssue{invalidTmeIssue}},
+		},
+	}
+	sendAllInvalid := testStruct{
+		scenarioName:       "send_allInvalid",
+		filePath:           "../resources/multipleGraphsInList.json",
+		endpoint:           "/transform/send",
+		expectedStatusCode: 400,
+		expectedConcepts: []conceptResponse{
+			{ConceptUUID: testUUID, Status: "SyntacticallyIncorrect", Error: "bad Request: Type has not been set for concept: 20db1bd6-59f9-4404-adb5-3165a448f8b0)", Code: ErrCodeMissingConceptType},
+			{ConceptUUID: "95f00e25-9a5f-45ec-8ad8-5607d021c74b", Status: "SyntacticallyIncorrect", Error: "bad Request: Type has not been set for concept: 95f00e25-9a5f-45ec-8ad8-5607d021c74b)", Code: ErrCodeMissingConceptType},
+		},
+	}
+
+	testScenarios := []testStruct{transformAllConcepts, transformPartiallyInvalid, sendPartiallyInvalid, sendAllInvalid}
+
+	for _, scenario := range testScenarios {
+		r := mux.NewRouter()
+		client := &countingHTTPClient{statusCode: 200}
+		h := NewHandler(NewTransformerService(TOPIC, WriterAddress, client, createLogger()), mockConsumer{}, createLogger())
+		h.RegisterHandlers(r)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newRequest("POST", scenario.endpoint, readFile(t, scenario.filePath)))
+		assert.Equal(t, scenario.expectedStatusCode, rec.Code, "Scenario: "+scenario.scenarioName+" failed")
+
+		var response conceptsResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), "Scenario: "+scenario.scenarioName+" failed")
+		assert.Equal(t, scenario.expectedConcepts, response.Concepts, "Scenario: "+scenario.scenarioName+" failed")
+	}
+}
+
+func TestMultiConceptTransformUsesTheCanonicalEncoding(t *testing.T) {
+	location := `{"@id": "http://www.ft.com/ontology/managedlocation/%s", "@type": ["http://www.ft.com/ontology/Location"], "http://www.ft.com/ontology/managedlocation/dbpediaId": [{"@value": "http://dbpedia.org/resource/Trinidad_&_Tobago"}]}`
+	single := fmt.Sprintf(`{"@graph": [%s]}`, fmt.Sprintf(location, testUUID))
+	multiple := fmt.Sprintf(`{"@graph": [%s, %s]}`, fmt.Sprintf(location, testUUID), fmt.Sprintf(location, "95f00e25-9a5f-45ec-8ad8-5607d021c74b"))
+
+	r := mux.NewRouter()
+	h := NewHandler(NewTransformerService(TOPIC, WriterAddress, nil, createLogger()), mockConsumer{}, createLogger())
+	h.RegisterHandlers(r)
+
+	singleRec := httptest.NewRecorder()
+	r.ServeHTTP(singleRec, newRequest("POST", "/transform", single))
+	assert.Equal(t, http.StatusOK, singleRec.Code)
+
+	multipleRec := httptest.NewRecorder()
+	r.ServeHTTP(multipleRec, newRequest("POST", "/transform", multiple))
+	assert.Equal(t, http.StatusOK, multipleRec.Code)
+	assert.NotContains(t, multipleRec.Body.String(), `\u0026`)
+
+	var response conceptsResponse
+	assert.NoError(t, json.Unmarshal(multipleRec.Body.Bytes(), &response))
+	if assert.Len(t, response.Concepts, 2) {
+		assert.Equal(t, strings.TrimSuffix(singleRec.Body.String(), "\n"), string(response.Concepts[0].UppConcordance), "a concordance should be encoded the same whatever the size of the payload")
+	}
+}
+
+// transformedConcordance returns the encoding of a concordance record reported by the transform endpoints.
+func transformedConcordance(t *testing.T, uppConcordance UppConcordance) json.RawMessage {
+	encoded, err := encodeTransformedConcordance(uppConcordance)
+	assert.NoError(t, err)
+	return encoded
+}
+
+func TestProcessKafkaMessageWithMultipleConcepts(t *testing.T) {
+	client := &countingHTTPClient{statusCode: 200}
+	producer := &mockProducer{}
+	h := NewHandler(NewTransformerService(TOPIC, WriterAddress, client, createLogger()), mockConsumer{}, createLogger(), WithDeadLetterProducer(producer))
+
+	h.ProcessKafkaMessage(kafka.NewFTMessage(map[string]string{transactionIDHeader: "tid_multi"}, readFile(t, "../resources/multipleConcepts.json")))
+	assert.Equal(t, 2, client.count())
+	assert.Empty(t, producer.messages)
+
+	h.ProcessKafkaMessage(kafka.NewFTMessage(map[string]string{transactionIDHeader: "tid_partial"}, readFile(t, "../resources/multipleConceptsPartiallyInvalid.json")))
+	assert.Equal(t, 3, client.count(), "the valid concept should still be sent")
+	if assert.Len(t, producer.messages, 1) {
+		assert.Contains(t, producer.messages[0].Body, "concept 95f00e25-9a5f-45ec-8ad8-5607d021c74b: Bad Request: Concordance id AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789 is not a valid TME Id")
+	}
+}
@@ -0,0 +1,246 @@
+package smartlogic
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	skippedWritesMetric = "skipped_concordance_writes"
+
+	// hashStoreCompactionThreshold is the number of lines superseded by later ones which the file of a hash store may
+	// hold, beyond one per concept, before it is compacted.
+	hashStoreCompactionThreshold = 10000
+)
+
+// ConcordanceHashStore remembers a hash of the last concordance record successfully sent for every concept.
+type ConcordanceHashStore interface {
+	Get(uuid string) (string, bool)
+	Put(uuid string, hash string) error
+	Close() error
+}
+
+// fileHashStore keeps the hashes in memory and persists them to an append-only file of "<uuid> <hash>" lines,
+// which is compacted every time the store is opened, and whenever it holds more superseded lines than the compaction
+// threshold and than current ones.
+type fileHashStore struct {
+	path         string
+	file         *os.File
+	hashes       map[string]string
+	lines        int
+	compactAfter int
+	mu           sync.Mutex
+	log          *logger.UPPLogger
+}
+
+func NewFileHashStore(path string, log *logger.UPPLogger) (ConcordanceHashStore, error) {
+	hashes, err := readHashes(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading concordance hash store %s: %w", path, err)
+	}
+	if err = compactHashes(path, hashes); err != nil {
+		return nil, fmt.Errorf("compacting concordance hash store %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening concordance hash store %s: %w", path, err)
+	}
+	return &fileHashStore{
+		path:         path,
+		file:         file,
+		hashes:       hashes,
+		lines:        len(hashes),
+		compactAfter: hashStoreCompactionThreshold,
+		log:          log,
+	}, nil
+}
+
+func (s *fileHashStore) Get(uuid string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, found := s.hashes[uuid]
+	return hash, found
+}
+
+func (s *fileHashStore) Put(uuid string, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.file, "%s %s\n", uuid, hash); err != nil {
+		return err
+	}
+	s.hashes[uuid] = hash
+	s.lines++
+	// the hash is recorded whether or not the compaction succeeds, it is simply tried again on the next Put
+	if superseded := s.lines - len(s.hashes); superseded > s.compactAfter && superseded > len(s.hashes) {
+		if err := s.compact(); err != nil {
+			s.log.WithError(err).Warn("Could not compact concordance hash store")
+		}
+	}
+	return nil
+}
+
+// compact rewrites the file with a single line per concept and appends to the new file from then on.
+func (s *fileHashStore) compact() error {
+	if err := compactHashes(s.path, s.hashes); err != nil {
+		return fmt.Errorf("compacting concordance hash store %s: %w", s.path, err)
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening concordance hash store %s: %w", s.path, err)
+	}
+	if err = s.file.Close(); err != nil {
+		err = fmt.Errorf("closing concordance hash store %s: %w", s.path, err)
+	}
+	s.file, s.lines = file, len(s.hashes)
+	return err
+}
+
+func (s *fileHashStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func readHashes(path string) (map[string]string, error) {
+	hashes := map[string]string{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// a torn last line after a crash is ignored, the concept will simply be written again
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			hashes[fields[0]] = fields[1]
+		}
+	}
+	return hashes, scanner.Err()
+}
+
+func compactHashes(path string, hashes map[string]string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for uuid, hash := range hashes {
+		if _, err = fmt.Fprintf(w, "%s %s\n", uuid, hash); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// WithHashStore makes the TransformerService skip sending a concordance record identical to the last one
+// successfully sent for the same concept.
+func WithHashStore(store ConcordanceHashStore) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.hashStore = store
+	}
+}
+
+func concordanceHash(uuid string, uppConcordance UppConcordance) (string, error) {
+	var record interface{} = uppConcordance
+	if len(uppConcordance.ConcordedIds) == 0 {
+		record = concordanceTombstone{ConceptUUID: uuid, Deleted: true}
+	}
+	data, err := encodeRecord(record)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sendIfModified calls send unless the concordance record is the same as the last one sent for the concept.
+// force sends the record regardless, and the hash is only recorded once every sink accepted the record. Records of the
+// same concept are sent one at a time, so the hash recorded is always the one of the record sent last.
+func (ts *TransformerService) sendIfModified(uuid string, uppConcordance UppConcordance, tid string, force bool, send func(string, UppConcordance, string) (status, error)) (status, error) {
+	if ts.hashStore == nil {
+		return send(uuid, uppConcordance, tid)
+	}
+	defer ts.conceptLocks.lock(uuid)()
+
+	hash, err := concordanceHash(uuid, uppConcordance)
+	if err != nil {
+		ts.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Warn("Could not hash concordance record; sending it anyway")
+		return send(uuid, uppConcordance, tid)
+	}
+
+	if lastHash, found := ts.hashStore.Get(uuid); found && lastHash == hash && !force {
+		metrics.GetOrRegisterCounter(skippedWritesMetric, metrics.DefaultRegistry).Inc(1)
+		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Info("Concordance record is unchanged since it was last sent; skipping it")
+		return NotModified, nil
+	}
+
+	updateStatus, err := send(uuid, uppConcordance, tid)
+	if err != nil {
+		return updateStatus, err
+	}
+	if err = ts.hashStore.Put(uuid, hash); err != nil {
+		ts.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Could not record hash of the concordance record sent")
+	}
+	return updateStatus, nil
+}
+
+// conceptLocks serialises the sending of the records of a concept between the Kafka workers and the HTTP endpoints.
+type conceptLocks struct {
+	locks map[string]*conceptLock
+	mu    sync.Mutex
+}
+
+type conceptLock struct {
+	sync.Mutex
+	holders int
+}
+
+func newConceptLocks() *conceptLocks {
+	return &conceptLocks{locks: map[string]*conceptLock{}}
+}
+
+// lock waits for the lock of the concept and returns the function releasing it.
+func (l *conceptLocks) lock(uuid string) func() {
+	l.mu.Lock()
+	lock, found := l.locks[uuid]
+	if !found {
+		lock = &conceptLock{}
+		l.locks[uuid] = lock
+	}
+	lock.holders++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if lock.holders--; lock.holders == 0 {
+			delete(l.locks, uuid)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package smartlogic
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/gorilla/mux"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileHashStorePersistsHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes")
+
+	store, err := NewFileHashStore(path, createLogger())
+	assert.NoError(t, err)
+	_, found := store.Get(testUUID)
+	assert.False(t, found)
+
+	assert.NoError(t, store.Put(testUUID, "first"))
+	assert.NoError(t, store.Put(testUUID, "second"))
+	assert.NoError(t, store.Put(concordedTmeUUID, "other"))
+	assert.NoError(t, store.Close())
+
+	// a torn line left by a crash is ignored
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, _ = f.WriteString("2d3e16e0-61cb")
+	assert.NoError(t, f.Close())
+
+	store, err = NewFileHashStore(path, createLogger())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	hash, found := store.Get(testUUID)
+	assert.True(t, found)
+	assert.Equal(t, "second", hash)
+	hash, found = store.Get(concordedTmeUUID)
+	assert.True(t, found)
+	assert.Equal(t, "other", hash)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, content, 2*(len(testUUID)+1+len("second")+1)-1, "store should have been compacted")
+}
+
+func TestFileHashStoreCompactsWhileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes")
+	store, err := NewFileHashStore(path, createLogger())
+	assert.NoError(t, err)
+	store.(*fileHashStore).compactAfter = 3
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, store.Put(testUUID, fmt.Sprintf("hash%d", i)))
+	}
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, testUUID+" hash4\n", string(content), "store should have been compacted once it held 4 superseded lines")
+
+	assert.NoError(t, store.Put(testUUID, "hash5"))
+	assert.NoError(t, store.Close())
+	store, err = NewFileHashStore(path, createLogger())
+	assert.NoError(t, err)
+	defer store.Close()
+	hash, found := store.Get(testUUID)
+	assert.True(t, found)
+	assert.Equal(t, "hash5", hash)
+}
+
+func TestFileHashStoreRecordsHashWhenCompactionFails(t *testing.T) {
+	store, err := NewFileHashStore(filepath.Join(t.TempDir(), "hashes"), createLogger())
+	assert.NoError(t, err)
+	defer store.Close()
+	store.(*fileHashStore).compactAfter = 0
+	store.(*fileHashStore).path = filepath.Join(t.TempDir(), "missing", "hashes")
+
+	assert.NoError(t, store.Put(testUUID, "first"))
+	assert.NoError(t, store.Put(testUUID, "second"))
+	assert.NoError(t, store.Put(testUUID, "third"), "a failed compaction should not fail the Put")
+	assert.Equal(t, 3, store.(*fileHashStore).lines, "store should not have been compacted")
+	hash, found := store.Get(testUUID)
+	assert.True(t, found)
+	assert.Equal(t, "third", hash)
+}
+
+func TestSendIfModifiedSendsOneRecordOfAConceptAtATime(t *testing.T) {
+	store, err := NewFileHashStore(filepath.Join(t.TempDir(), "hashes"), createLogger())
+	assert.NoError(t, err)
+	defer store.Close()
+	ts := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithHashStore(store))
+
+	var inFlight, maxInFlight int32
+	send := func(string, UppConcordance, string) (status, error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			seen := atomic.LoadInt32(&maxInFlight)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxInFlight, seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return ValidConcept, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uppConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{{Authority: ConcordanceAuthorityTme, AuthorityValue: fmt.Sprint(i), UUID: concordedTmeUUID}}}
+			_, err := ts.sendIfModified(testUUID, uppConcordance, "tid_concurrent", false, send)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxInFlight)
+	assert.Empty(t, ts.conceptLocks.locks, "released locks should be forgotten")
+}
+
+func TestSendIfModified(t *testing.T) {
+	withConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{concordedTmeID}}
+	otherConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{concordedTmeID, {Authority: ConcordanceAuthorityFactset, UUID: testUUID}}}
+	noConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{}}
+
+	store, err := NewFileHashStore(filepath.Join(t.TempDir(), "hashes"), createLogger())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	client := &countingHTTPClient{statusCode: 200}
+	ts := NewTransformerService(TOPIC, WriterAddress, client, createLogger(), WithHashStore(store))
+	skippedBefore := metrics.GetOrRegisterCounter(skippedWritesMetric, metrics.DefaultRegistry).Count()
+
+	type testStruct struct {
+		testName       string
+		uppConcordance UppConcordance
+		force          bool
+		statusCode     int
+		expectedStatus status
+		expectedCalls  int
+	}
+
+	firstWrite := testStruct{testName: "firstWrite", uppConcordance: withConcordance, statusCode: 200, expectedStatus: ValidConcept, expectedCalls: 1}
+	unchangedWriteIsSkipped := testStruct{testName: "unchangedWriteIsSkipped", uppConcordance: withConcordance, statusCode: 200, expectedStatus: NotModified, expectedCalls: 1}
+	forcedWrite := testStruct{testName: "forcedWrite", uppConcordance: withConcordance, force: true, statusCode: 200, expectedStatus: ValidConcept, expectedCalls: 2}
+	changedWrite := testStruct{testName: "changedWrite", uppConcordance: otherConcordance, statusCode: 200, expectedStatus: ValidConcept, expectedCalls: 3}
+	failedDelete := testStruct{testName: "failedDelete", uppConcordance: noConcordance, statusCode: 503, expectedStatus: InternalError, expectedCalls: 4}
+	failedDeleteIsNotRemembered := testStruct{testName: "failedDeleteIsNotRemembered", uppConcordance: noConcordance, statusCode: 204, expectedStatus: NoContent, expectedCalls: 5}
+	unchangedDeleteIsSkipped := testStruct{testName: "unchangedDeleteIsSkipped", uppConcordance: noConcordance, statusCode: 204, expectedStatus: NotModified, expectedCalls: 5}
+
+	testScenarios := []testStruct{firstWrite, unchangedWriteIsSkipped, forcedWrite, changedWrite, failedDelete, failedDeleteIsNotRemembered, unchangedDeleteIsSkipped}
+
+	for _, scenario := range testScenarios {
+		client.statusCode = scenario.statusCode
+		updateStatus, _ := ts.sendIfModified(testUUID, scenario.uppConcordance, "tid_hash", scenario.force, ts.makeRelevantRequest)
+		assert.Equal(t, scenario.expectedStatus, updateStatus, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedCalls, client.count(), "Scenario: "+scenario.testName+" failed")
+	}
+	assert.Equal(t, int64(2), metrics.GetOrRegisterCounter(skippedWritesMetric, metrics.DefaultRegistry).Count()-skippedBefore)
+}
+
+func TestSendHandlerSkipsUnmodifiedConcordance(t *testing.T) {
+	store, err := NewFileHashStore(filepath.Join(t.TempDir(), "hashes"), createLogger())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	r := mux.NewRouter()
+	client := &countingHTTPClient{statusCode: 200}
+	h := NewHandler(NewTransformerService(TOPIC, WriterAddress, client, createLogger(), WithHashStore(store)), mockConsumer{}, createLogger())
+	h.RegisterHandlers(r)
+
+	type testStruct struct {
+		scenarioName   string
+		endpoint       string
+		expectedResult string
+		expectedCalls  int
+	}
+
+	firstSend := testStruct{scenarioName: "firstSend", endpoint: "/transform/send", expectedResult: "Concordance record forwarded to writer", expectedCalls: 1}
+	unchangedSend := testStruct{scenarioName: "unchangedSend", endpoint: "/transform/send", expectedResult: "Concordance record not modified", expectedCalls: 1}
+	forcedSend := testStruct{scenarioName: "forcedSend", endpoint: "/transform/send?force=true", expectedResult: "Concordance record forwarded to writer", expectedCalls: 2}
+
+	for _, scenario := range []testStruct{firstSend, unchangedSend, forcedSend} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newRequest("POST", scenario.endpoint, readFile(t, "../resources/multipleTmeAndFactsetIds.json")))
+		assert.Equal(t, 200, rec.Code, scenario.scenarioName)
+		assert.Contains(t, rec.Body.String(), scenario.expectedResult, scenario.scenarioName)
+		assert.Equal(t, scenario.expectedCalls, client.count(), scenario.scenarioName)
+	}
+}
+
+func TestProcessKafkaMessageForcesUnmodifiedConcordance(t *testing.T) {
+	store, err := NewFileHashStore(filepath.Join(t.TempDir(), "hashes"), createLogger())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	client := &countingHTTPClient{statusCode: 200}
+	h := NewHandler(NewTransformerService(TOPIC, WriterAddress, client, createLogger(), WithHashStore(store)), mockConsumer{}, createLogger())
+	payload := readFile(t, "../resources/multipleTmeAndFactsetIds.json")
+
+	type testStruct struct {
+		scenarioName  string
+		headers       map[string]string
+		expectedCalls int
+	}
+
+	firstMessage := testStruct{scenarioName: "firstMessage", headers: map[string]string{transactionIDHeader: "tid_1"}, expectedCalls: 1}
+	unchangedMessage := testStruct{scenarioName: "unchangedMessage", headers: map[string]string{transactionIDHeader: "tid_2"}, expectedCalls: 1}
+	forcedMessage := testStruct{scenarioName: "forcedMessage", headers: map[string]string{transactionIDHeader: "tid_3", forceSendHeader: "true"}, expectedCalls: 2}
+
+	for _, scenario := range []testStruct{firstMessage, unchangedMessage, forcedMessage} {
+		h.ProcessKafkaMessage(kafka.NewFTMessage(scenario.headers, payload))
+		assert.Equal(t, scenario.expectedCalls, client.count(), scenario.scenarioName)
+	}
+}
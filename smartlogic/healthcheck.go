@@ -28,7 +28,18 @@ func (h *ConcordanceTransformerHandler) RegisterAdminHandlers(router *mux.Router
 	monitoringRouter = httphandlers.TransactionAwareRequestLoggingHandler(h.log.Logger, monitoringRouter)
 	monitoringRouter = httphandlers.HTTPMetricsHandler(metrics.DefaultRegistry, monitoringRouter)
 
-	var checks = []fthealth.Check{h.concordanceRwNeo4jHealthCheck(), h.kafkaHealthCheck(), h.kafkaMonitorCheck()}
+	var checks = []fthealth.Check{h.kafkaHealthCheck(), h.kafkaMonitorCheck(), h.kafkaWorkersCheck()}
+	if h.transformer.hasHTTPSink() {
+		checks = append([]fthealth.Check{h.concordanceRwNeo4jHealthCheck()}, checks...)
+	}
+	for _, sink := range h.transformer.sinks {
+		if ks, ok := sink.(*kafkaSink); ok {
+			checks = append(checks, h.kafkaSinkHealthCheck(ks))
+		}
+	}
+	if h.deadLetterProducer != nil {
+		checks = append(checks, h.deadLetterHealthCheck())
+	}
 
 	timedHC := fthealth.TimedHealthCheck{
 		HealthCheck: fthealth.HealthCheck{
@@ -52,14 +63,15 @@ func (h *ConcordanceTransformerHandler) gtg() gtg.Status {
 		return gtgCheck(h.checkKafkaConnectivity)
 	}
 
-	conceptsRwS3Check := func() gtg.Status {
-		return gtgCheck(h.checkConcordanceRwConnectivity)
+	checks := []gtg.StatusChecker{kafkaQueueCheck}
+	if h.transformer.hasHTTPSink() {
+		conceptsRwS3Check := func() gtg.Status {
+			return gtgCheck(h.checkConcordanceRwConnectivity)
+		}
+		checks = append(checks, conceptsRwS3Check)
 	}
 
-	return gtg.FailFastParallelCheck([]gtg.StatusChecker{
-		kafkaQueueCheck,
-		conceptsRwS3Check,
-	})()
+	return gtg.FailFastParallelCheck(checks)()
 }
 
 func gtgCheck(handler func() (string, error)) gtg.Status {
@@ -90,6 +102,45 @@ func (h *ConcordanceTransformerHandler) kafkaMonitorCheck() fthealth.Check {
 	}
 }
 
+func (h *ConcordanceTransformerHandler) kafkaWorkersCheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Editorial updates of concordance records in SmartLogic will be delayed.",
+		Name:             "Check Kafka message processing workers",
+		PanicGuide:       panicGuideURL,
+		Severity:         3,
+		TechnicalSummary: `Reports the number of workers processing Kafka messages in parallel and fails when all of their queues are full. Check the health of concordances-rw-neo4j or increase the concurrency of this service`,
+		Checker:          h.checkKafkaWorkers,
+	}
+}
+
+func (h *ConcordanceTransformerHandler) kafkaSinkHealthCheck(sink *kafkaSink) fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   businessImpact,
+		Name:             "Check connectivity to the Kafka concordance sink topic",
+		PanicGuide:       panicGuideURL,
+		Severity:         3,
+		TechnicalSummary: `Check that kafka is healthy in this cluster; if so restart this service`,
+		Checker: func() (string, error) {
+			if err := sink.producer.ConnectivityCheck(); err != nil {
+				h.log.WithError(err).Error("error verifying open connection to the Kafka concordance sink topic")
+				return "Error connecting with Kafka concordance sink producer", err
+			}
+			return "Successfully connected to Kafka concordance sink producer", nil
+		},
+	}
+}
+
+func (h *ConcordanceTransformerHandler) deadLetterHealthCheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Smartlogic messages which fail to be transformed or written will only be visible in the logs",
+		Name:             "Check connectivity to the dead letter Kafka topic",
+		PanicGuide:       panicGuideURL,
+		Severity:         3,
+		TechnicalSummary: `Check that kafka is healthy in this cluster; if so restart this service`,
+		Checker:          h.checkDeadLetterConnectivity,
+	}
+}
+
 func (h *ConcordanceTransformerHandler) concordanceRwNeo4jHealthCheck() fthealth.Check {
 	return fthealth.Check{
 		BusinessImpact:   businessImpact,
@@ -138,6 +189,25 @@ func (h *ConcordanceTransformerHandler) checkKafkaConnectivity() (string, error)
 	return "Successfully connected to Kafka", nil
 }
 
+func (h *ConcordanceTransformerHandler) checkDeadLetterConnectivity() (string, error) {
+	if err := h.deadLetterProducer.ConnectivityCheck(); err != nil {
+		h.log.WithError(err).Error("error verifying open connection to the dead letter topic")
+		return "Error connecting with Kafka dead letter producer", err
+	}
+	return "Successfully connected to Kafka dead letter producer", nil
+}
+
+func (h *ConcordanceTransformerHandler) checkKafkaWorkers() (string, error) {
+	if h.workers == nil {
+		return "Kafka messages are processed sequentially by 1 worker", nil
+	}
+	msg := fmt.Sprintf("Kafka messages are processed by %d workers, %d messages pending", h.workers.concurrency(), h.workers.pendingMessages())
+	if h.workers.pendingMessages() >= h.workers.capacity() {
+		return msg, errors.New("all Kafka message processing workers are saturated")
+	}
+	return msg, nil
+}
+
 func (h *ConcordanceTransformerHandler) monitorKafkaConnectivity() (string, error) {
 	if err := h.consumer.MonitorCheck(); err != nil {
 		h.log.WithError(err).Error("kafka consumer is lagging")
@@ -0,0 +1,168 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// jsonLDContext holds the term definitions of a JSON-LD @context, enough to expand the compact predicates
+// Smartlogic may send into the IRIs the authority registry is keyed by.
+type jsonLDContext struct {
+	vocab string
+	terms map[string]string
+}
+
+// withContext returns the context resulting from processing a local @context on top of c.
+// Remote contexts can't be dereferenced and are rejected rather than silently ignored.
+func (c jsonLDContext) withContext(raw json.RawMessage) (jsonLDContext, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return c, nil
+	}
+
+	var definitions []json.RawMessage
+	if raw[0] == '[' {
+		if err := json.Unmarshal(raw, &definitions); err != nil {
+			return c, err
+		}
+	} else {
+		definitions = []json.RawMessage{raw}
+	}
+
+	result := jsonLDContext{vocab: c.vocab, terms: make(map[string]string, len(c.terms))}
+	for term, iri := range c.terms {
+		result.terms[term] = iri
+	}
+	for _, definition := range definitions {
+		var remote string
+		if json.Unmarshal(definition, &remote) == nil {
+			return c, fmt.Errorf("remote @context %s is not supported", remote)
+		}
+		if string(definition) == "null" {
+			result = jsonLDContext{terms: map[string]string{}}
+			continue
+		}
+		var entries map[string]json.RawMessage
+		if err := json.Unmarshal(definition, &entries); err != nil {
+			return c, errors.New("@context must be an object, a list of objects or null")
+		}
+		if err := result.define(entries); err != nil {
+			return c, err
+		}
+	}
+	return result, nil
+}
+
+func (c *jsonLDContext) define(entries map[string]json.RawMessage) error {
+	raw := map[string]string{}
+	for term, value := range entries {
+		if term == "@vocab" {
+			if err := json.Unmarshal(value, &c.vocab); err != nil {
+				return errors.New("@vocab must be a string")
+			}
+			continue
+		}
+		if strings.HasPrefix(term, "@") {
+			continue
+		}
+
+		if string(value) == "null" {
+			delete(c.terms, term)
+			continue
+		}
+		var iri string
+		if json.Unmarshal(value, &iri) != nil {
+			var expanded struct {
+				ID *string `json:"@id"`
+			}
+			if err := json.Unmarshal(value, &expanded); err != nil {
+				return fmt.Errorf("invalid definition of term %s", term)
+			}
+			iri = term
+			if expanded.ID != nil {
+				iri = *expanded.ID
+			}
+		}
+		raw[term] = iri
+	}
+
+	for term, iri := range raw {
+		c.terms[term] = iri
+	}
+	// term definitions may refer to each other, e.g. "sys:Model": "owl:Ontology"
+	for term, iri := range raw {
+		c.terms[term], _ = c.expandIRI(iri, map[string]bool{term: true})
+	}
+	c.vocab, _ = c.expandIRI(c.vocab, map[string]bool{})
+	return nil
+}
+
+// expand returns the IRI of a predicate and whether it could be resolved to an absolute IRI.
+func (c jsonLDContext) expand(key string) (string, bool) {
+	return c.expandIRI(key, map[string]bool{})
+}
+
+func (c jsonLDContext) expandIRI(value string, seen map[string]bool) (string, bool) {
+	if value == "" || strings.HasPrefix(value, "@") {
+		return value, true
+	}
+	if iri, found := c.terms[value]; found && !seen[value] && iri != value {
+		seen[value] = true
+		return c.expandIRI(iri, seen)
+	}
+	if prefix, suffix, found := strings.Cut(value, ":"); found {
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return value, true
+		}
+		if iri, defined := c.terms[prefix]; defined && !seen[prefix] {
+			seen[prefix] = true
+			expanded, ok := c.expandIRI(iri, seen)
+			return expanded + suffix, ok
+		}
+		return value, false
+	}
+	if c.vocab != "" {
+		return c.vocab + value, true
+	}
+	return value, false
+}
+
+// expandPredicates maps the IRI of every predicate of the concept to the keys it is written as in the payload,
+// several keys expanding to the same IRI, and remembers the keys which couldn't be resolved.
+func (c *Concept) expandPredicates(parent jsonLDContext) error {
+	ctx, err := parent.withContext(c.context)
+	if err != nil {
+		return fmt.Errorf("invalid Request Json: %w", err)
+	}
+
+	keys := sortedKeys(c.properties)
+	c.predicates = make(map[string][]string, len(keys))
+	c.unresolved = nil
+	for _, key := range keys {
+		iri, resolved := ctx.expand(key)
+		if !resolved {
+			c.unresolved = append(c.unresolved, key)
+		}
+		c.predicates[iri] = append(c.predicates[iri], key)
+	}
+	c.readCoreFields(ctx)
+	c.readMergedConcepts(ctx)
+	return nil
+}
+
+// sortedKeys returns the keys of a JSON object in order, so properties are always processed the same way.
+func sortedKeys(properties map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// localName returns the last segment of an IRI or compact IRI.
+func localName(iri string) string {
+	return iri[strings.LastIndexAny(iri, "/#:")+1:]
+}
@@ -0,0 +1,88 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextExpansion(t *testing.T) {
+	tmeConcordance := ConcordedID{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"}
+	otherTmeConcordance := ConcordedID{Authority: ConcordanceAuthorityTme, AuthorityValue: "ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321", UUID: "83f63c7e-1641-3c7b-81e4-378ae3c6c2ad"}
+	factsetConcordance := ConcordedID{Authority: ConcordanceAuthorityFactset, AuthorityValue: "000D63-E", UUID: "8d3aba95-02d9-3802-afc0-b99bb9b1139e"}
+
+	type testStruct struct {
+		testName             string
+		payload              string
+		expectedConcordedIDs []ConcordedID
+		expectedDecodeError  string
+		expectedConvertError string
+	}
+
+	expandedIRIs := testStruct{
+		testName:             "expandedIRIs",
+		payload:              `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}]}], "@context": {"sem": "http://www.smartlogic.com/2014/08/semaphore-core#"}}`,
+		expectedConcordedIDs: []ConcordedID{tmeConcordance},
+	}
+	compactIRIs := testStruct{
+		testName:             "compactIRIs",
+		payload:              `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["ft:Brand"], "ft:TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}], "ft:factsetIdentifier": {"@value": "000D63-E"}}], "@context": {"ft": "http://www.ft.com/ontology/"}}`,
+		expectedConcordedIDs: []ConcordedID{factsetConcordance, tmeConcordance},
+	}
+	vocab := testStruct{
+		testName:             "vocab",
+		payload:              `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["Brand"], "TMEIdentifier": ["AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"]}], "@context": {"@vocab": "ft:", "ft": "http://www.ft.com/ontology/"}}`,
+		expectedConcordedIDs: []ConcordedID{tmeConcordance},
+	}
+	termDefinitions := testStruct{
+		testName:             "termDefinitions",
+		payload:              `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["ft:Brand"], "tme": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}], "factset": [{"@value": "000D63-E"}]}], "@context": [{"ft": "http://www.ft.com/ontology/", "tme": "ft:TMEIdentifier"}, {"factset": {"@id": "ft:factsetIdentifier", "@type": "xsd:string"}}]}`,
+		expectedConcordedIDs: []ConcordedID{factsetConcordance, tmeConcordance},
+	}
+	conceptContext := testStruct{
+		testName:             "conceptContext",
+		payload:              `{"@graph": [{"@context": {"ft": "http://www.ft.com/ontology/"}, "@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["ft:Brand"], "ft:TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}]}]}`,
+		expectedConcordedIDs: []ConcordedID{tmeConcordance},
+	}
+	mixedForms := testStruct{
+		testName:             "mixedForms",
+		payload:              `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["ft:Brand"], "ft:TMEIdentifier": [{"@value": "ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321"}], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}]}], "@context": {"ft": "http://www.ft.com/ontology/"}}`,
+		expectedConcordedIDs: []ConcordedID{tmeConcordance, otherTmeConcordance},
+	}
+	undefinedPrefix := testStruct{
+		testName:             "undefinedPrefix",
+		payload:              `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["ft:Brand"], "ft:TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}]}], "@context": {"sem": "http://www.smartlogic.com/2014/08/semaphore-core#"}}`,
+		expectedConvertError: "bad Request: Predicate ft:TMEIdentifier could not be expanded with the @context of the payload",
+	}
+	remoteContext := testStruct{
+		testName:            "remoteContext",
+		payload:             `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["ft:Brand"]}], "@context": "https://smartlogic.ft.com/context.jsonld"}`,
+		expectedDecodeError: "invalid Request Json: remote @context https://smartlogic.ft.com/context.jsonld is not supported",
+	}
+
+	testScenarios := []testStruct{expandedIRIs, compactIRIs, vocab, termDefinitions, conceptContext, mixedForms, undefinedPrefix, remoteContext}
+
+	transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger())
+	for _, scenario := range testScenarios {
+		var concepts ConceptData
+		err := json.Unmarshal([]byte(scenario.payload), &concepts)
+		if scenario.expectedDecodeError != "" {
+			assert.EqualError(t, err, scenario.expectedDecodeError, "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+
+		results, err := transformer.convertToUppConcordances(concepts, "tid_context")
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		if !assert.Len(t, results, 1, "Scenario: "+scenario.testName+" failed") {
+			continue
+		}
+		if scenario.expectedConvertError != "" {
+			assert.EqualError(t, results[0].err, scenario.expectedConvertError, "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, results[0].err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, results[0].uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
@@ -0,0 +1,173 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	owlNamespace  = "http://www.w3.org/2002/07/owl#"
+	skosNamespace = "http://www.w3.org/2004/02/skos/core#"
+
+	owlSameAsPredicate      = owlNamespace + "sameAs"
+	skosExactMatchPredicate = skosNamespace + "exactMatch"
+
+	mergeCyclesMetric = "merge_cycles"
+)
+
+// equivalencePrefixes are the prefixes of the equivalence predicates, used when the payload doesn't define them.
+var equivalencePrefixes = map[string]string{
+	"owl":  owlNamespace,
+	"skos": skosNamespace,
+}
+
+// readMergedConcepts reads the Smartlogic concepts merged into the concept: those its owl:sameAs and skos:exactMatch
+// predicates link to by their http://www.ft.com/thing/ URI. Links to anything else are equivalences to other
+// vocabularies, not merges, and are ignored.
+func (c *Concept) readMergedConcepts(ctx jsonLDContext) {
+	ctx = ctx.withDefaultPrefixes(equivalencePrefixes)
+	c.Merged = nil
+	for _, key := range sortedKeys(c.properties) {
+		if iri, _ := ctx.expand(key); iri != owlSameAsPredicate && iri != skosExactMatchPredicate {
+			continue
+		}
+		path := predicatePath(c.index, key)
+		var items []json.RawMessage
+		isList := json.Unmarshal(c.properties[key], &items) == nil
+		if !isList {
+			items = []json.RawMessage{c.properties[key]}
+		}
+		for i, item := range items {
+			itemPath := path
+			if isList {
+				itemPath = fmt.Sprintf("%s[%d]", path, i)
+			}
+			var reference struct {
+				ID string `json:"@id"`
+			}
+			if json.Unmarshal(item, &reference) != nil || !strings.HasPrefix(reference.ID, ThingURIPrefix) {
+				continue
+			}
+			c.Merged = append(c.Merged, IdentifierValue{Value: reference.ID, path: itemPath + "['@id']"})
+		}
+	}
+}
+
+// appendMergedConcepts concords the concepts merged into the concept under the Smartlogic authority. A link to the
+// concept itself is skipped with a warning, and so is a second link to the same concept. Links which aren't the URI
+// of a concept are invalid.
+func (ts *TransformerService) appendMergedConcepts(concordances []ConcordedID, concept Concept, conceptUUID string, tid string) ([]ConcordedID, []ValidationIssue) {
+	var issues []ValidationIssue
+	for _, link := range concept.Merged {
+		mergedUUID := strings.TrimPrefix(link.Value, ThingURIPrefix)
+		logEntry := ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID, "merged_uuid": mergedUUID, "path": link.path})
+		switch {
+		case !uuidMatcher.MatchString(mergedUUID):
+			issues = append(issues, ValidationIssue{
+				Code:      ErrCodeInvalidIdentifier,
+				Authority: ConcordanceAuthoritySmartlogic,
+				Value:     link.Value,
+				Reason:    "Bad Request: Concordance id " + link.Value + " is not a valid Smartlogic Id",
+				Path:      link.path,
+			})
+		case mergedUUID == conceptUUID:
+			logEntry.Warn("Payload from Smartlogic has a concept merged into itself. Skipping it")
+		case concordancesContainValue(concordances, mergedUUID):
+			logEntry.Debug("Payload from Smartlogic links the same merged concept more than once. Skipping it")
+		default:
+			concordances = append(concordances, ConcordedID{Authority: ConcordanceAuthoritySmartlogic, AuthorityValue: mergedUUID, UUID: mergedUUID})
+		}
+	}
+	return concordances, issues
+}
+
+// mergeIndex remembers the concepts merged into every concept, as last sent to the writer, so a merge cycle is
+// detected even when its concepts come in different payloads. It is only kept in memory.
+type mergeIndex struct {
+	merges map[string][]string
+	mu     sync.Mutex
+}
+
+func newMergeIndex() *mergeIndex {
+	return &mergeIndex{merges: map[string][]string{}}
+}
+
+// record replaces the concepts merged into the concept with those of the concordance record sent for it.
+func (i *mergeIndex) record(conceptUUID string, uppConcordance UppConcordance) {
+	merged := mergedUUIDs(uppConcordance)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(merged) == 0 {
+		delete(i.merges, conceptUUID)
+		return
+	}
+	i.merges[conceptUUID] = merged
+}
+
+func (i *mergeIndex) snapshot() map[string][]string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	merges := make(map[string][]string, len(i.merges))
+	for conceptUUID, merged := range i.merges {
+		merges[conceptUUID] = merged
+	}
+	return merges
+}
+
+func mergedUUIDs(uppConcordance UppConcordance) []string {
+	var merged []string
+	for _, concordance := range uppConcordance.ConcordedIds {
+		if concordance.Authority == ConcordanceAuthoritySmartlogic {
+			merged = append(merged, concordance.UUID)
+		}
+	}
+	return merged
+}
+
+// rejectMergeCycles fails the concepts of a payload which are merged, directly or through other concepts of the
+// payload or concepts already sent, into themselves: the writer would otherwise be left with concepts concorded into
+// each other.
+func (ts *TransformerService) rejectMergeCycles(results []conceptResult, tid string) {
+	merges := ts.knownMerges.snapshot()
+	for _, result := range results {
+		if result.err == nil {
+			merges[result.conceptUUID] = mergedUUIDs(result.uppConcordance)
+		}
+	}
+	for i, result := range results {
+		if result.err != nil || !mergedIntoItself(merges, result.conceptUUID) {
+			continue
+		}
+		metrics.GetOrRegisterCounter(mergeCyclesMetric, metrics.DefaultRegistry).Inc(1)
+		err := &ConceptError{
+			Code:        ErrCodeMergeCycle,
+			ConceptUUID: result.conceptUUID,
+			Authority:   ConcordanceAuthoritySmartlogic,
+			Message:     fmt.Sprintf("bad Request: Concept %s is merged into itself through the concepts merged into it", result.conceptUUID),
+		}
+		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": result.conceptUUID, "alert_tag": "ConceptLoadingInvalidConcordance"}).Error(err)
+		results[i] = conceptResult{status: SemanticallyIncorrect, conceptUUID: result.conceptUUID, err: err}
+	}
+}
+
+// mergedIntoItself reports whether the concept can be reached again by following the merges from it.
+func mergedIntoItself(merges map[string][]string, conceptUUID string) bool {
+	visited := map[string]bool{}
+	pending := append([]string{}, merges[conceptUUID]...)
+	for len(pending) > 0 {
+		next := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if next == conceptUUID {
+			return true
+		}
+		if !visited[next] {
+			visited[next] = true
+			pending = append(pending, merges[next]...)
+		}
+	}
+	return false
+}
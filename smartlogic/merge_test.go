@@ -0,0 +1,199 @@
+package smartlogic
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMergedConcepts(t *testing.T) {
+	var concepts ConceptData
+	assert.NoError(t, json.NewDecoder(bytes.NewBufferString(readFile(t, "../resources/mergedConcepts.json"))).Decode(&concepts))
+	assert.Equal(t, []IdentifierValue{
+		{Value: "http://www.ft.com/thing/95f00e25-9a5f-45ec-8ad8-5607d021c74b", path: "$['@graph'][0]['http://www.w3.org/2002/07/owl#sameAs'][0]['@id']"},
+		{Value: "http://www.ft.com/thing/95f00e25-9a5f-45ec-8ad8-5607d021c74b", path: "$['@graph'][0]['skos:exactMatch'][0]['@id']"},
+	}, concepts.Concepts[0].Merged)
+}
+
+func TestMergedConcepts(t *testing.T) {
+	conceptUUID := "20db1bd6-59f9-4404-adb5-3165a448f8b0"
+	mergedUUID := "95f00e25-9a5f-45ec-8ad8-5607d021c74b"
+	concordedTme := ConcordedID{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"}
+	concordedMerge := ConcordedID{Authority: ConcordanceAuthoritySmartlogic, AuthorityValue: mergedUUID, UUID: mergedUUID}
+
+	type testStruct struct {
+		testName             string
+		links                string
+		expectedConcordedIDs []ConcordedID
+		expectedCode         ErrorCode
+		expectedError        string
+	}
+
+	sameAs := testStruct{testName: "sameAs", links: `"owl:sameAs": {"@id": "http://www.ft.com/thing/` + mergedUUID + `"}`, expectedConcordedIDs: []ConcordedID{concordedMerge, concordedTme}}
+	exactMatch := testStruct{testName: "exactMatch", links: `"http://www.w3.org/2004/02/skos/core#exactMatch": [{"@id": "http://www.ft.com/thing/` + mergedUUID + `"}]`, expectedConcordedIDs: []ConcordedID{concordedMerge, concordedTme}}
+	externalMatchIsIgnored := testStruct{testName: "externalMatchIsIgnored", links: `"skos:exactMatch": [{"@id": "http://www.wikidata.org/entity/Q23240"}]`, expectedConcordedIDs: []ConcordedID{concordedTme}}
+	selfReferenceIsSkipped := testStruct{testName: "selfReferenceIsSkipped", links: `"owl:sameAs": [{"@id": "http://www.ft.com/thing/` + conceptUUID + `"}]`, expectedConcordedIDs: []ConcordedID{concordedTme}}
+	invalidURI := testStruct{
+		testName:      "invalidURI",
+		links:         `"owl:sameAs": [{"@id": "http://www.ft.com/thing/95f00e25"}]`,
+		expectedCode:  ErrCodeInvalidIdentifier,
+		expectedError: "Bad Request: Concordance id http://www.ft.com/thing/95f00e25 is not a valid Smartlogic Id",
+	}
+
+	testScenarios := []testStruct{sameAs, exactMatch, externalMatchIsIgnored, selfReferenceIsSkipped, invalidURI}
+
+	transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger())
+	for _, scenario := range testScenarios {
+		payload := `{"@id": "http://www.ft.com/thing/` + conceptUUID + `", "@type": ["http://www.ft.com/ontology/product/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}], ` + scenario.links + `}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_merge")
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedCode, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestMergedConceptsFollowConceptTypePolicy(t *testing.T) {
+	mergedUUID := "95f00e25-9a5f-45ec-8ad8-5607d021c74b"
+	stripping, err := NewConceptTypePolicies([]ConceptTypePolicy{{Type: "Brand", Authorities: []string{ConcordanceAuthorityTme}, OnViolation: violationStrip}})
+	assert.NoError(t, err)
+	withoutAuthorities, err := NewConceptTypePolicies([]ConceptTypePolicy{{Type: "Membership", Authorities: []string{}}})
+	assert.NoError(t, err)
+
+	type testStruct struct {
+		testName             string
+		conceptType          string
+		opts                 []TransformerOption
+		expectedConcordedIDs []ConcordedID
+		expectedError        string
+	}
+
+	mergesAreRejected := testStruct{
+		testName:      "mergesAreRejected",
+		conceptType:   "http://www.ft.com/ontology/Membership",
+		opts:          []TransformerOption{WithConceptTypePolicies(withoutAuthorities)},
+		expectedError: "bad Request: Concept type Membership does not support concordance",
+	}
+	builtinMembershipKeepsMerges := testStruct{
+		testName:             "builtinMembershipKeepsMerges",
+		conceptType:          "http://www.ft.com/ontology/Membership",
+		expectedConcordedIDs: []ConcordedID{{Authority: ConcordanceAuthoritySmartlogic, AuthorityValue: mergedUUID, UUID: mergedUUID}},
+	}
+	mergesAreStripped := testStruct{
+		testName:             "mergesAreStripped",
+		conceptType:          "http://www.ft.com/ontology/product/Brand",
+		opts:                 []TransformerOption{WithConceptTypePolicies(stripping)},
+		expectedConcordedIDs: []ConcordedID{},
+	}
+
+	for _, scenario := range []testStruct{mergesAreRejected, builtinMembershipKeepsMerges, mergesAreStripped} {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), scenario.opts...)
+		payload := `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["` + scenario.conceptType + `"], "owl:sameAs": [{"@id": "http://www.ft.com/thing/` + mergedUUID + `"}]}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_merge_policy")
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, ErrCodeConcordanceNotSupported, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestMergeCycles(t *testing.T) {
+	first := "20db1bd6-59f9-4404-adb5-3165a448f8b0"
+	second := "95f00e25-9a5f-45ec-8ad8-5607d021c74b"
+	third := "1a96ee7a-a4af-3a56-852c-60420b0b8da6"
+	concept := func(conceptUUID string, mergedUUID string) string {
+		return `{"@id": "http://www.ft.com/thing/` + conceptUUID + `", "@type": ["http://www.ft.com/ontology/product/Brand"], "owl:sameAs": [{"@id": "http://www.ft.com/thing/` + mergedUUID + `"}]}`
+	}
+
+	type testStruct struct {
+		testName       string
+		concepts       []string
+		expectedCodes  []ErrorCode
+		expectedCycles int64
+	}
+
+	chain := testStruct{testName: "chain", concepts: []string{concept(first, second), concept(second, third)}, expectedCodes: []ErrorCode{"", ""}}
+	twoConceptCycle := testStruct{
+		testName:       "twoConceptCycle",
+		concepts:       []string{concept(first, second), concept(second, first)},
+		expectedCodes:  []ErrorCode{ErrCodeMergeCycle, ErrCodeMergeCycle},
+		expectedCycles: 2,
+	}
+	threeConceptCycle := testStruct{
+		testName:       "threeConceptCycle",
+		concepts:       []string{concept(first, second), concept(second, third), concept(third, first)},
+		expectedCodes:  []ErrorCode{ErrCodeMergeCycle, ErrCodeMergeCycle, ErrCodeMergeCycle},
+		expectedCycles: 3,
+	}
+
+	transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger())
+	for _, scenario := range []testStruct{chain, twoConceptCycle, threeConceptCycle} {
+		cyclesBefore := metrics.GetOrRegisterCounter(mergeCyclesMetric, metrics.DefaultRegistry).Count()
+
+		var concepts ConceptData
+		assert.NoError(t, json.Unmarshal([]byte(`{"@graph": [`+strings.Join(scenario.concepts, ", ")+`]}`), &concepts), "Scenario: "+scenario.testName+" failed")
+		results, err := transformer.convertToUppConcordances(concepts, "tid_cycle")
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		for i, result := range results {
+			if scenario.expectedCodes[i] == "" {
+				assert.NoError(t, result.err, "Scenario: "+scenario.testName+" failed")
+				continue
+			}
+			assert.Equal(t, SemanticallyIncorrect, result.status, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedCodes[i], errorCode(result.err, result.status), "Scenario: "+scenario.testName+" failed")
+		}
+		cycles := metrics.GetOrRegisterCounter(mergeCyclesMetric, metrics.DefaultRegistry).Count() - cyclesBefore
+		assert.Equal(t, scenario.expectedCycles, cycles, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestMergeCyclesAcrossPayloads(t *testing.T) {
+	first := "20db1bd6-59f9-4404-adb5-3165a448f8b0"
+	second := "95f00e25-9a5f-45ec-8ad8-5607d021c74b"
+	payload := func(conceptUUID string, mergedUUIDs ...string) string {
+		links := make([]string, len(mergedUUIDs))
+		for i, mergedUUID := range mergedUUIDs {
+			links[i] = `{"@id": "http://www.ft.com/thing/` + mergedUUID + `"}`
+		}
+		return `{"@graph": [{"@id": "http://www.ft.com/thing/` + conceptUUID + `", "@type": ["http://www.ft.com/ontology/product/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}], "owl:sameAs": [` + strings.Join(links, ", ") + `]}]}`
+	}
+
+	type testStruct struct {
+		testName       string
+		payload        string
+		expectedStatus status
+		expectedCode   ErrorCode
+	}
+
+	firstMergesSecond := testStruct{testName: "firstMergesSecond", payload: payload(first, second), expectedStatus: ValidConcept}
+	secondMergesFirst := testStruct{testName: "secondMergesFirst", payload: payload(second, first), expectedStatus: SemanticallyIncorrect, expectedCode: ErrCodeMergeCycle}
+	firstIsUnmerged := testStruct{testName: "firstIsUnmerged", payload: payload(first), expectedStatus: ValidConcept}
+	secondMergesFirstOnceUnmerged := testStruct{testName: "secondMergesFirstOnceUnmerged", payload: payload(second, first), expectedStatus: ValidConcept}
+
+	testScenarios := []testStruct{firstMergesSecond, secondMergesFirst, firstIsUnmerged, secondMergesFirstOnceUnmerged}
+
+	transformer := NewTransformerService(TOPIC, WriterAddress, &mockHTTPClient{statusCode: 200}, createLogger())
+	for _, scenario := range testScenarios {
+		updateStatus, err := transformer.handleConcordanceEvent(scenario.payload, "tid_cycle", false)
+		assert.Equal(t, scenario.expectedStatus, updateStatus, "Scenario: "+scenario.testName+" failed")
+		if scenario.expectedCode == "" {
+			assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.Equal(t, scenario.expectedCode, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+	}
+}
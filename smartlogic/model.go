@@ -2,6 +2,7 @@ package smartlogic
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 )
 
@@ -9,147 +10,143 @@ type ConceptData struct {
 	Concepts []Concept `json:"@graph"`
 }
 
+// Concept is a concept of a Smartlogic payload. GUID, Statuses, PrefLabels and AltLabels hold its core Semaphore
+// fields: sem:guid, sem:status and sem:hasStatus, skosxl:prefLabel and skosxl:altLabel. Merged holds the URIs of
+// the concepts merged into it.
 type Concept struct {
-	ID             string   `json:"@id"`
-	Types          []string `json:"@type,omitempty"`
-	currentConcept Concepter
-}
-
-type Concepter interface {
-	TmeIdentifiers() []TmeID
-	FactsetIdentifiers() []FactsetID
-	DbpediaIdentifiers() []LocationType
-	GeonamesIdentifiers() []LocationType
-	WikidataIdentifiers() []LocationType
-}
-
-type ConceptML struct {
-	TmeIdentifiersValue      []TmeID        `json:"http://www.ft.com/ontology/managedlocation/TMEIdentifier,omitempty"`
-	FactsetIdentifiersValue  []FactsetID    `json:"http://www.ft.com/ontology/managedlocation/factsetIdentifier,omitempty"`
-	DbpediaIdentifiersValue  []LocationType `json:"http://www.ft.com/ontology/managedlocation/dbpediaId,omitempty"`
-	GeonamesIdentifiersValue []LocationType `json:"http://www.ft.com/ontology/managedlocation/geonamesId,omitempty"`
-	WikidataIdentifiersValue []LocationType `json:"http://www.ft.com/ontology/managedlocation/wikidataId,omitempty"`
-}
-
-type ConceptEditorial struct {
-	TmeIdentifiersValue      []TmeID        `json:"http://www.ft.com/ontology/TMEIdentifier,omitempty"`
-	FactsetIdentifiersValue  []FactsetID    `json:"http://www.ft.com/ontology/factsetIdentifier,omitempty"`
-	WikidataIdentifiersValue []LocationType `json:"http://www.ft.com/ontology/wikidataIdentifier,omitempty"`
-	GeonamesIdentifiersValue []LocationType `json:"http://www.ft.com/ontology/geonamesIdentifier,omitempty"`
-}
-
-type TmeID struct {
-	Value string `json:"@value"`
-}
-
-type FactsetID struct {
-	Language string `json:"@language"`
+	ID         string            `json:"@id"`
+	Types      []string          `json:"@type,omitempty"`
+	GUID       string            `json:"-"`
+	Statuses   []string          `json:"-"`
+	PrefLabels []Label           `json:"-"`
+	AltLabels  []Label           `json:"-"`
+	Merged     []IdentifierValue `json:"-"`
+	index      int
+	context    json.RawMessage
+	properties map[string]json.RawMessage
+	predicates map[string][]string
+	unresolved []string
+}
+
+// IdentifierValue is a JSON-LD value object holding an identifier of the concept in another authority.
+type IdentifierValue struct {
+	Type     string `json:"@type,omitempty"`
+	Language string `json:"@language,omitempty"`
 	Value    string `json:"@value"`
+	path     string
 }
 
+// UppConcordance is the concordance record of a concept. ConceptType is the UPP type the concept resolves to, and
+// Duplicates lists the identifiers dropped by a dedupe policy; like the taxonomy of TME concordances they are only
+// reported by the transform endpoints, and never sent to the writer.
 type UppConcordance struct {
-	Authority    string        `json:"authority"`
-	ConceptUUID  string        `json:"uuid"`
-	ConcordedIds []ConcordedID `json:"concordances"`
+	Authority    string                `json:"authority"`
+	ConceptUUID  string                `json:"uuid"`
+	ConceptType  string                `json:"conceptType,omitempty"`
+	ConcordedIds []ConcordedID         `json:"concordances"`
+	Duplicates   []DuplicateIdentifier `json:"duplicates,omitempty"`
 }
 
+// ConcordedID is a concordance of the concept. Taxonomy holds the decoded taxonomy of a TME identifier; it is only
+// reported by /transform to help support staff, and never sent to the writer.
 type ConcordedID struct {
 	Authority      string `json:"authority"`
 	AuthorityValue string `json:"authorityValue,omitempty"`
 	UUID           string `json:"uuid"`
+	Taxonomy       string `json:"taxonomy,omitempty"`
 }
 
-type LocationType struct {
-	Type  string `json:"@type"`
-	Value string `json:"@value"`
+// UnmarshalJSON expands the predicates of every concept with the @context of the payload, so identifiers are
+// found whether Smartlogic sends them as IRIs, compact IRIs or terms.
+func (d *ConceptData) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		Concepts []Concept       `json:"@graph"`
+		Context  json.RawMessage `json:"@context"`
+	}{}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	ctx, err := jsonLDContext{terms: map[string]string{}}.withContext(aux.Context)
+	if err != nil {
+		return fmt.Errorf("invalid Request Json: %w", err)
+	}
+	for i := range aux.Concepts {
+		aux.Concepts[i].index = i
+		if err = aux.Concepts[i].expandPredicates(ctx); err != nil {
+			return err
+		}
+	}
+	d.Concepts = aux.Concepts
+	return nil
 }
 
 func (c *Concept) UnmarshalJSON(data []byte) error {
 	aux := &struct {
 		ID    string   `json:"@id"`
 		Types []string `json:"@type,omitempty"`
-		*ConceptML
-		*ConceptEditorial
 	}{}
-
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
-	if strings.Contains(aux.ID, "managedlocation") {
-		if aux.ConceptML == nil {
-			c.currentConcept = &ConceptML{}
-		} else {
-			c.currentConcept = aux.ConceptML
-		}
-	} else {
-		if aux.ConceptEditorial == nil {
-			c.currentConcept = &ConceptEditorial{}
-		} else {
-			c.currentConcept = aux.ConceptEditorial
-		}
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal(data, &properties); err != nil {
+		return err
 	}
+	c.context = properties["@context"]
+	delete(properties, "@id")
+	delete(properties, "@type")
+	delete(properties, "@context")
 
 	c.ID = aux.ID
 	c.Types = aux.Types
-	return nil
-}
-
-func (c Concept) TmeIdentifiers() []TmeID {
-	return c.currentConcept.TmeIdentifiers()
-}
-
-func (c Concept) FactsetIdentifiers() []FactsetID {
-	return c.currentConcept.FactsetIdentifiers()
+	c.properties = properties
+	return c.expandPredicates(jsonLDContext{terms: map[string]string{}})
 }
 
-func (c *Concept) DbpediaIdentifiers() []LocationType {
-	return c.currentConcept.DbpediaIdentifiers()
-}
-
-func (c *Concept) GeonamesIdentifiers() []LocationType {
-	return c.currentConcept.GeonamesIdentifiers()
-}
-
-func (c *Concept) WikidataIdentifiers() []LocationType {
-	return c.currentConcept.WikidataIdentifiers()
-}
-
-func (c ConceptEditorial) DbpediaIdentifiers() []LocationType {
-	return nil
-}
-
-func (c ConceptEditorial) GeonamesIdentifiers() []LocationType {
-	return c.GeonamesIdentifiersValue
-}
-
-func (c ConceptEditorial) WikidataIdentifiers() []LocationType {
-	return c.WikidataIdentifiersValue
-}
-
-func (c ConceptEditorial) TmeIdentifiers() []TmeID {
-	return c.TmeIdentifiersValue
-}
-
-func (c ConceptEditorial) FactsetIdentifiers() []FactsetID {
-	return c.FactsetIdentifiersValue
-}
-
-func (c ConceptML) TmeIdentifiers() []TmeID {
-	return c.TmeIdentifiersValue
-}
-
-func (c ConceptML) FactsetIdentifiers() []FactsetID {
-	return c.FactsetIdentifiersValue
-}
-
-func (c ConceptML) DbpediaIdentifiers() []LocationType {
-	return c.DbpediaIdentifiersValue
-}
-
-func (c ConceptML) GeonamesIdentifiers() []LocationType {
-	return c.GeonamesIdentifiersValue
-}
+// model returns the concept model the predicates of the concept belong to.
+func (c Concept) model() string {
+	if strings.Contains(c.ID, "managedlocation") {
+		return conceptModelManagedLocation
+	}
+	return conceptModelEditorial
+}
+
+// identifiers returns the values held under the predicate, if any. Like JSON-LD it accepts a single value as well
+// as a list, and plain strings as well as value objects. Malformed values are reported as issues.
+func (c Concept) identifiers(predicate string) ([]IdentifierValue, []ValidationIssue) {
+	var values []IdentifierValue
+	var issues []ValidationIssue
+	for _, key := range c.predicates[predicate] {
+		path := predicatePath(c.index, key)
+		raw := c.properties[key]
+		var items []json.RawMessage
+		isList := json.Unmarshal(raw, &items) == nil
+		if !isList {
+			items = []json.RawMessage{raw}
+		}
 
-func (c ConceptML) WikidataIdentifiers() []LocationType {
-	return c.WikidataIdentifiersValue
+		for i, item := range items {
+			itemPath := path
+			if isList {
+				itemPath = fmt.Sprintf("%s[%d]", path, i)
+			}
+			var value IdentifierValue
+			if err := json.Unmarshal(item, &value.Value); err == nil {
+				value.path = itemPath
+				values = append(values, value)
+				continue
+			}
+			if err := json.Unmarshal(item, &value); err != nil {
+				issues = append(issues, ValidationIssue{
+					Code:   ErrCodeMalformedIdentifier,
+					Reason: fmt.Sprintf("bad Request: %s is not a list of JSON-LD values: %s", key, err),
+					Path:   itemPath,
+				})
+				continue
+			}
+			value.path = itemPath + "['@value']"
+			values = append(values, value)
+		}
+	}
+	return values, issues
 }
@@ -0,0 +1,110 @@
+package smartlogic
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy describes how requests to the concordances-rw-neo4j are retried on the Kafka path.
+// Only transient failures are retried: connection errors, 429 and 5xx responses. A retry only sends the record to the
+// sinks which haven't accepted it yet.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var noRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// WithRetryPolicy makes the TransformerService retry transient writer failures when processing Kafka messages.
+func WithRetryPolicy(policy RetryPolicy) TransformerOption {
+	return func(ts *TransformerService) {
+		if policy.MaxAttempts < 1 {
+			policy.MaxAttempts = 1
+		}
+		ts.retryPolicy = policy
+	}
+}
+
+func (ts *TransformerService) makeRelevantRequestWithRetry(uuid string, uppConcordance UppConcordance, tid string) (status, error) {
+	var resp sinkResponse
+	var err error
+	delivery := &sinkDelivery{}
+	for attempt := 1; ; attempt++ {
+		resp, err = ts.sendToSinks(uuid, uppConcordance, tid, delivery)
+		if err == nil || !isTransientFailure(resp) || attempt >= ts.retryPolicy.MaxAttempts {
+			return resp.status, err
+		}
+
+		if ts.retryPolicy.MaxBackoff > 0 && resp.retryAfter > ts.retryPolicy.MaxBackoff {
+			ts.log.WithError(err).WithFields(map[string]interface{}{
+				"transaction_id": tid,
+				"UUID":           uuid,
+				"attempt":        attempt,
+				"retry_after":    resp.retryAfter.String(),
+				"max_backoff":    ts.retryPolicy.MaxBackoff.String(),
+			}).Warn("Writer asked to be retried later than the maximum backoff; giving up")
+			return resp.status, err
+		}
+
+		delay := ts.retryPolicy.backoff(attempt, resp.retryAfter)
+		ts.log.WithError(err).WithFields(map[string]interface{}{
+			"transaction_id": tid,
+			"UUID":           uuid,
+			"attempt":        attempt,
+			"max_attempts":   ts.retryPolicy.MaxAttempts,
+			"retry_in":       delay.String(),
+		}).Warn("Transient failure sending concordance record to writer; retrying")
+		ts.sleep(delay)
+	}
+}
+
+func isTransientFailure(resp sinkResponse) bool {
+	switch resp.status {
+	case ServiceUnavailable:
+		return true
+	case InternalError:
+		return resp.statusCode >= 500 || resp.statusCode == http.StatusTooManyRequests
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the next attempt: exponential with jitter capped at MaxBackoff, or the delay the
+// writer asked for through Retry-After when it is longer. A Retry-After longer than MaxBackoff is never waited for, the
+// record is given up on instead.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := p.InitialBackoff << (attempt - 1)
+	if delay <= 0 || (p.MaxBackoff > 0 && delay > p.MaxBackoff) {
+		delay = p.MaxBackoff
+	}
+	if delay > 0 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if retryAt, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(retryAt); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
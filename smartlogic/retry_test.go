@@ -0,0 +1,169 @@
+package smartlogic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockResponse struct {
+	statusCode int
+	retryAfter string
+	err        error
+}
+
+type sequenceHTTPClient struct {
+	responses []mockResponse
+	calls     int
+}
+
+func (c *sequenceHTTPClient) Do(_ *http.Request) (*http.Response, error) {
+	r := c.responses[len(c.responses)-1]
+	if c.calls < len(c.responses) {
+		r = c.responses[c.calls]
+	}
+	c.calls++
+	header := http.Header{}
+	if r.retryAfter != "" {
+		header.Set("Retry-After", r.retryAfter)
+	}
+	return &http.Response{Body: io.NopCloser(bytes.NewReader(nil)), StatusCode: r.statusCode, Header: header}, r.err
+}
+
+func TestMakeRelevantRequestWithRetry(t *testing.T) {
+	withConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{concordedTmeID}}
+	noConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{}}
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+	type testStruct struct {
+		testName       string
+		uppConcordance UppConcordance
+		responses      []mockResponse
+		expectedCalls  int
+		expectedStatus status
+		expectError    bool
+		expectedDelays []time.Duration
+	}
+
+	succeedsAfterUnavailableWriter := testStruct{
+		testName:       "succeedsAfterUnavailableWriter",
+		uppConcordance: withConcordance,
+		responses:      []mockResponse{{err: errors.New("connection refused")}, {statusCode: 200}},
+		expectedCalls:  2,
+		expectedStatus: ValidConcept,
+	}
+	succeedsAfterServerError := testStruct{
+		testName:       "succeedsAfterServerError",
+		uppConcordance: noConcordance,
+		responses:      []mockResponse{{statusCode: 503}, {statusCode: 500}, {statusCode: 204}},
+		expectedCalls:  3,
+		expectedStatus: NoContent,
+	}
+	givesUpAfterMaxAttempts := testStruct{
+		testName:       "givesUpAfterMaxAttempts",
+		uppConcordance: withConcordance,
+		responses:      []mockResponse{{statusCode: 502}},
+		expectedCalls:  3,
+		expectedStatus: InternalError,
+		expectError:    true,
+	}
+	doesNotRetryClientError := testStruct{
+		testName:       "doesNotRetryClientError",
+		uppConcordance: withConcordance,
+		responses:      []mockResponse{{statusCode: 400}, {statusCode: 200}},
+		expectedCalls:  1,
+		expectedStatus: InternalError,
+		expectError:    true,
+	}
+	respectsRetryAfter := testStruct{
+		testName:       "respectsRetryAfter",
+		uppConcordance: withConcordance,
+		responses:      []mockResponse{{statusCode: 503, retryAfter: "7"}, {statusCode: 200}},
+		expectedCalls:  2,
+		expectedStatus: ValidConcept,
+		expectedDelays: []time.Duration{7 * time.Second},
+	}
+	givesUpWhenRetryAfterExceedsMaxBackoff := testStruct{
+		testName:       "givesUpWhenRetryAfterExceedsMaxBackoff",
+		uppConcordance: withConcordance,
+		responses:      []mockResponse{{statusCode: 429, retryAfter: "3600"}, {statusCode: 201}},
+		expectedCalls:  1,
+		expectedStatus: InternalError,
+		expectError:    true,
+	}
+
+	testScenarios := []testStruct{succeedsAfterUnavailableWriter, succeedsAfterServerError, givesUpAfterMaxAttempts, doesNotRetryClientError, respectsRetryAfter, givesUpWhenRetryAfterExceedsMaxBackoff}
+
+	for _, scenario := range testScenarios {
+		client := &sequenceHTTPClient{responses: scenario.responses}
+		var delays []time.Duration
+		ts := NewTransformerService(TOPIC, WriterAddress, client, createLogger(), WithRetryPolicy(policy))
+		ts.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+		updateStatus, err := ts.makeRelevantRequestWithRetry(testUUID, scenario.uppConcordance, "tid_retry")
+		assert.Equal(t, scenario.expectedCalls, client.calls, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedStatus, updateStatus, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectError, err != nil, "Scenario: "+scenario.testName+" failed")
+		assert.Len(t, delays, scenario.expectedCalls-1, "Scenario: "+scenario.testName+" failed")
+		if scenario.expectedDelays != nil {
+			assert.Equal(t, scenario.expectedDelays, delays, "Scenario: "+scenario.testName+" failed")
+		}
+	}
+}
+
+func TestRetryOnlyResendsToFailedSinks(t *testing.T) {
+	withConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{concordedTmeID}}
+	producer := &mockProducer{}
+	client := &sequenceHTTPClient{responses: []mockResponse{{statusCode: 503}, {statusCode: 502}, {statusCode: 200}}}
+	sinks := WithSinks(NewKafkaSink(producer, createLogger()), NewHTTPSink(WriterAddress, client, createLogger()))
+	ts := NewTransformerService(TOPIC, WriterAddress, client, createLogger(), sinks, WithRetryPolicy(RetryPolicy{MaxAttempts: 5}))
+	ts.sleep = func(time.Duration) {}
+
+	updateStatus, err := ts.makeRelevantRequestWithRetry(testUUID, withConcordance, "tid_retry")
+	assert.NoError(t, err)
+	assert.Equal(t, ValidConcept, updateStatus)
+	assert.Equal(t, 3, client.calls)
+	assert.Len(t, producer.messages, 1, "the record should be published once however often the writer is retried")
+}
+
+func TestHandleConcordanceEventDoesNotRetryInvalidPayload(t *testing.T) {
+	client := &sequenceHTTPClient{responses: []mockResponse{{statusCode: 503}}}
+	ts := NewTransformerService(TOPIC, WriterAddress, client, createLogger(), WithRetryPolicy(RetryPolicy{MaxAttempts: 5}))
+	ts.sleep = func(time.Duration) {}
+
+	updateStatus, err := ts.handleConcordanceEvent(readFile(t, "../resources/invalidTmeId.json"), "tid_retry", false)
+	assert.Error(t, err)
+	assert.Equal(t, SyntacticallyIncorrect, updateStatus)
+	assert.Equal(t, 0, client.calls)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	for attempt := 1; attempt <= 6; attempt++ {
+		expectedMax := time.Second << (attempt - 1)
+		if expectedMax > policy.MaxBackoff {
+			expectedMax = policy.MaxBackoff
+		}
+		delay := policy.backoff(attempt, 0)
+		assert.GreaterOrEqual(t, delay, expectedMax/2, "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, expectedMax, "attempt %d", attempt)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-3"))
+	assert.Equal(t, 12*time.Second, parseRetryAfter("12"))
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	delay := parseRetryAfter(future)
+	assert.Greater(t, delay, 50*time.Second)
+	assert.LessOrEqual(t, delay, time.Minute)
+}
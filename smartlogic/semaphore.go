@@ -0,0 +1,250 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	semaphoreCoreNamespace = "http://www.smartlogic.com/2014/08/semaphore-core#"
+	skosxlNamespace        = "http://www.w3.org/2008/05/skos-xl#"
+
+	semGUIDPredicate         = semaphoreCoreNamespace + "guid"
+	semStatusPredicate       = semaphoreCoreNamespace + "status"
+	semHasStatusPredicate    = semaphoreCoreNamespace + "hasStatus"
+	skosxlPrefLabelPredicate = skosxlNamespace + "prefLabel"
+	skosxlAltLabelPredicate  = skosxlNamespace + "altLabel"
+	skosxlLiteralForm        = skosxlNamespace + "literalForm"
+
+	guidMismatchMetric  = "guid_mismatches"
+	statusDeletesMetric = "status_deletes"
+)
+
+// defaultDeletedStatuses are the statuses of concepts whose concordances are deleted, unless configured otherwise.
+var defaultDeletedStatuses = []string{"deprecated", "obsolete"}
+
+// semaphorePrefixes are the prefixes Semaphore writes its core fields with, used when the payload doesn't define them.
+var semaphorePrefixes = map[string]string{
+	"sem":    semaphoreCoreNamespace,
+	"skosxl": skosxlNamespace,
+}
+
+// Label is a literal form of a SKOS-XL label of a concept.
+type Label struct {
+	ID       string
+	Language string
+	Value    string
+}
+
+// GUIDMismatchPolicy decides what happens to a concept whose sem:guid doesn't match the UUID of its @id.
+type GUIDMismatchPolicy string
+
+const (
+	// GUIDMismatchWarn keeps the UUID of the @id and logs the mismatch.
+	GUIDMismatchWarn GUIDMismatchPolicy = "warn"
+	// GUIDMismatchReject fails the concept with a GUID_MISMATCH error.
+	GUIDMismatchReject GUIDMismatchPolicy = "reject"
+	// GUIDMismatchPreferGUID concords the concept under its sem:guid and logs the mismatch.
+	GUIDMismatchPreferGUID GUIDMismatchPolicy = "prefer-guid"
+)
+
+// ParseGUIDMismatchPolicy returns the policy named by value.
+func ParseGUIDMismatchPolicy(value string) (GUIDMismatchPolicy, error) {
+	switch policy := GUIDMismatchPolicy(value); policy {
+	case GUIDMismatchWarn, GUIDMismatchReject, GUIDMismatchPreferGUID:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown guid mismatch policy %q", value)
+	}
+}
+
+// WithGUIDMismatchPolicy sets what happens to concepts whose sem:guid doesn't match their @id.
+func WithGUIDMismatchPolicy(policy GUIDMismatchPolicy) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.guidMismatchPolicy = policy
+	}
+}
+
+// WithDeletedStatuses sets the Semaphore statuses, compared case-insensitively, of the concepts whose concordances are
+// deleted rather than written. No status deletes concordances when none is given.
+func WithDeletedStatuses(statuses ...string) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.deletedStatuses = normaliseStatuses(statuses)
+	}
+}
+
+func normaliseStatuses(statuses []string) []string {
+	var normalised []string
+	for _, s := range statuses {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			normalised = append(normalised, s)
+		}
+	}
+	return normalised
+}
+
+// readCoreFields reads the sem:guid, the statuses and the SKOS-XL labels of the concept, expanding its predicates with
+// ctx and the Semaphore prefixes it doesn't define. Malformed values are ignored: they never prevent concordance.
+func (c *Concept) readCoreFields(ctx jsonLDContext) {
+	ctx = ctx.withDefaultPrefixes(semaphorePrefixes)
+	c.GUID, c.Statuses, c.PrefLabels, c.AltLabels = "", nil, nil, nil
+	for _, key := range sortedKeys(c.properties) {
+		raw := c.properties[key]
+		switch iri, _ := ctx.expand(key); iri {
+		case semGUIDPredicate:
+			if values := jsonLDValues(raw); len(values) > 0 {
+				c.GUID = strings.ToLower(strings.TrimSpace(values[0].Value))
+			}
+		case semStatusPredicate, semHasStatusPredicate:
+			c.Statuses = append(c.Statuses, semaphoreStatuses(raw, ctx)...)
+		case skosxlPrefLabelPredicate:
+			c.PrefLabels = append(c.PrefLabels, skosxlLabels(raw, ctx)...)
+		case skosxlAltLabelPredicate:
+			c.AltLabels = append(c.AltLabels, skosxlLabels(raw, ctx)...)
+		}
+	}
+}
+
+// withDefaultPrefixes returns the context with the prefixes it doesn't define yet.
+func (c jsonLDContext) withDefaultPrefixes(prefixes map[string]string) jsonLDContext {
+	result := jsonLDContext{vocab: c.vocab, terms: make(map[string]string, len(c.terms)+len(prefixes))}
+	for prefix, iri := range prefixes {
+		result.terms[prefix] = iri
+	}
+	for term, iri := range c.terms {
+		result.terms[term] = iri
+	}
+	return result
+}
+
+// jsonLDValues returns the values held by a JSON-LD property, a single value or a list of plain strings or value
+// objects, skipping anything else.
+func jsonLDValues(raw json.RawMessage) []IdentifierValue {
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		items = []json.RawMessage{raw}
+	}
+	var values []IdentifierValue
+	for _, item := range items {
+		var value IdentifierValue
+		if json.Unmarshal(item, &value.Value) == nil || json.Unmarshal(item, &value) == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// semaphoreStatuses returns the statuses held by a property, lowercased: literal values as they are, and statuses
+// referenced by @id by the last segment of their IRI, e.g. "deprecated" for sem:Deprecated.
+func semaphoreStatuses(raw json.RawMessage, ctx jsonLDContext) []string {
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		items = []json.RawMessage{raw}
+	}
+	var statuses []string
+	for _, item := range items {
+		var reference struct {
+			ID string `json:"@id"`
+		}
+		if json.Unmarshal(item, &reference) == nil && reference.ID != "" {
+			iri, _ := ctx.expand(reference.ID)
+			statuses = append(statuses, strings.ToLower(iri[strings.LastIndexAny(iri, "#/:")+1:]))
+			continue
+		}
+		for _, value := range jsonLDValues(item) {
+			statuses = append(statuses, strings.ToLower(strings.TrimSpace(value.Value)))
+		}
+	}
+	return statuses
+}
+
+// skosxlLabels returns the literal forms of the SKOS-XL labels held by a property.
+func skosxlLabels(raw json.RawMessage, ctx jsonLDContext) []Label {
+	var items []map[string]json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		var item map[string]json.RawMessage
+		if json.Unmarshal(raw, &item) != nil {
+			return nil
+		}
+		items = []map[string]json.RawMessage{item}
+	}
+	var labels []Label
+	for _, item := range items {
+		var id string
+		_ = json.Unmarshal(item["@id"], &id)
+		for _, key := range sortedKeys(item) {
+			if iri, _ := ctx.expand(key); iri != skosxlLiteralForm {
+				continue
+			}
+			for _, form := range jsonLDValues(item[key]) {
+				labels = append(labels, Label{ID: id, Language: form.Language, Value: form.Value})
+			}
+		}
+	}
+	return labels
+}
+
+// checkGUID compares the sem:guid of the concept with the UUID of its @id, and returns the UUID the concept is
+// concorded under following the guid mismatch policy. Every mismatch is counted and logged with an alert tag.
+func (ts *TransformerService) checkGUID(concept Concept, conceptUUID string, tid string) (string, *ConceptError) {
+	if concept.GUID == "" || concept.GUID == conceptUUID {
+		return conceptUUID, nil
+	}
+	metrics.GetOrRegisterCounter(guidMismatchMetric, metrics.DefaultRegistry).Inc(1)
+	err := &ConceptError{
+		Code:        ErrCodeGUIDMismatch,
+		ConceptUUID: conceptUUID,
+		Value:       concept.GUID,
+		Message:     fmt.Sprintf("bad Request: sem:guid %s does not match the @id of concept %s", concept.GUID, conceptUUID),
+	}
+	logEntry := ts.log.WithFields(map[string]interface{}{
+		"transaction_id": tid,
+		"UUID":           conceptUUID,
+		"guid":           concept.GUID,
+		"on_mismatch":    ts.guidMismatchPolicy,
+		"alert_tag":      alertTagGUIDMismatch,
+	})
+	switch {
+	case ts.guidMismatchPolicy == GUIDMismatchReject:
+		logEntry.Error(err)
+		return conceptUUID, err
+	case ts.guidMismatchPolicy == GUIDMismatchPreferGUID && uuidMatcher.MatchString(concept.GUID):
+		logEntry.Warn(fmt.Sprintf("%s; concording it under its sem:guid", err))
+		return concept.GUID, nil
+	default:
+		logEntry.Warn(fmt.Sprintf("%s; concording it under its @id", err))
+		return conceptUUID, nil
+	}
+}
+
+// deletedStatus returns the first status of the concept whose concordances are deleted, if any.
+func (ts *TransformerService) deletedStatus(concept Concept) string {
+	for _, conceptStatus := range concept.Statuses {
+		for _, deleted := range ts.deletedStatuses {
+			if conceptStatus == deleted {
+				return conceptStatus
+			}
+		}
+	}
+	return ""
+}
+
+// deleteForStatus returns an empty concordance record, which deletes the concordances of a concept whose status
+// is one of the deleted statuses, logging the status and the configured statuses it matched.
+func (ts *TransformerService) deleteForStatus(concept Concept, conceptUUID string, uppAuthority string, tid string) (UppConcordance, bool) {
+	conceptStatus := ts.deletedStatus(concept)
+	if conceptStatus == "" {
+		return UppConcordance{}, false
+	}
+	metrics.GetOrRegisterCounter(statusDeletesMetric, metrics.DefaultRegistry).Inc(1)
+	ts.log.WithFields(map[string]interface{}{
+		"transaction_id":   tid,
+		"UUID":             conceptUUID,
+		"status":           conceptStatus,
+		"deleted_statuses": ts.deletedStatuses,
+	}).Infof("Concept %s has status %s; deleting its concordances", conceptUUID, conceptStatus)
+	return UppConcordance{ConceptUUID: conceptUUID, Authority: uppAuthority, ConcordedIds: []ConcordedID{}}, true
+}
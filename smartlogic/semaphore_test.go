@@ -0,0 +1,168 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCoreFields(t *testing.T) {
+	type testStruct struct {
+		testName           string
+		payload            string
+		expectedGUID       string
+		expectedStatuses   []string
+		expectedPrefLabels []Label
+		expectedAltLabels  []Label
+	}
+
+	essex := Label{ID: "http://www.ft.com/thing/1a96ee7a-a4af-3a56-852c-60420b0b8da6/Essex_en", Language: "en", Value: "Essex"}
+
+	compactWithContext := testStruct{
+		testName:           "compactWithContext",
+		payload:            `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "sem:guid": [{"@value": "1a96ee7a-a4af-3a56-852c-60420b0b8da6"}], "sem:hasStatus": [{"@id": "sem:Deprecated"}], "skosxl:prefLabel": [{"@id": "http://www.ft.com/thing/1a96ee7a-a4af-3a56-852c-60420b0b8da6/Essex_en", "skosxl:literalForm": [{"@language": "en", "@value": "Essex"}]}]}], "@context": {"sem": "http://www.smartlogic.com/2014/08/semaphore-core#", "skosxl": "http://www.w3.org/2008/05/skos-xl#"}}`,
+		expectedGUID:       "1a96ee7a-a4af-3a56-852c-60420b0b8da6",
+		expectedStatuses:   []string{"deprecated"},
+		expectedPrefLabels: []Label{essex},
+	}
+	compactWithoutContext := testStruct{
+		testName:           "compactWithoutContext",
+		payload:            `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "sem:guid": "1A96EE7A-A4AF-3A56-852C-60420B0B8DA6", "sem:status": "Obsolete", "skosxl:prefLabel": {"@id": "http://www.ft.com/thing/1a96ee7a-a4af-3a56-852c-60420b0b8da6/Essex_en", "skosxl:literalForm": {"@language": "en", "@value": "Essex"}}}]}`,
+		expectedGUID:       "1a96ee7a-a4af-3a56-852c-60420b0b8da6",
+		expectedStatuses:   []string{"obsolete"},
+		expectedPrefLabels: []Label{essex},
+	}
+	expandedIRIs := testStruct{
+		testName:          "expandedIRIs",
+		payload:           `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "http://www.smartlogic.com/2014/08/semaphore-core#guid": [{"@value": "1a96ee7a-a4af-3a56-852c-60420b0b8da6"}], "http://www.w3.org/2008/05/skos-xl#altLabel": [{"http://www.w3.org/2008/05/skos-xl#literalForm": [{"@language": "en", "@value": "County of Essex"}, {"@language": "fr", "@value": "Comté d'Essex"}]}]}]}`,
+		expectedGUID:      "1a96ee7a-a4af-3a56-852c-60420b0b8da6",
+		expectedAltLabels: []Label{{Language: "en", Value: "County of Essex"}, {Language: "fr", Value: "Comté d'Essex"}},
+	}
+	prefixRedefined := testStruct{
+		testName: "prefixRedefined",
+		payload:  `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "sem:guid": [{"@value": "1a96ee7a-a4af-3a56-852c-60420b0b8da6"}]}], "@context": {"sem": "http://example.org/sem#"}}`,
+	}
+	malformedFieldsAreIgnored := testStruct{
+		testName: "malformedFieldsAreIgnored",
+		payload:  `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "sem:guid": [42], "skosxl:prefLabel": "Essex"}]}`,
+	}
+
+	testScenarios := []testStruct{compactWithContext, compactWithoutContext, expandedIRIs, prefixRedefined, malformedFieldsAreIgnored}
+
+	for _, scenario := range testScenarios {
+		var concepts ConceptData
+		assert.NoError(t, json.Unmarshal([]byte(scenario.payload), &concepts), "Scenario: "+scenario.testName+" failed")
+		concept := concepts.Concepts[0]
+		assert.Equal(t, scenario.expectedGUID, concept.GUID, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedStatuses, concept.Statuses, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedPrefLabels, concept.PrefLabels, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedAltLabels, concept.AltLabels, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestGUIDMismatchPolicies(t *testing.T) {
+	conceptUUID := "20db1bd6-59f9-4404-adb5-3165a448f8b0"
+	guid := "1a96ee7a-a4af-3a56-852c-60420b0b8da6"
+
+	type testStruct struct {
+		testName            string
+		policy              GUIDMismatchPolicy
+		guid                string
+		expectedConceptUUID string
+		expectedError       string
+		expectedMismatches  int64
+	}
+
+	matching := testStruct{testName: "matching", policy: GUIDMismatchReject, guid: conceptUUID, expectedConceptUUID: conceptUUID}
+	warn := testStruct{testName: "warn", policy: GUIDMismatchWarn, guid: guid, expectedConceptUUID: conceptUUID, expectedMismatches: 1}
+	reject := testStruct{
+		testName:            "reject",
+		policy:              GUIDMismatchReject,
+		guid:                guid,
+		expectedConceptUUID: conceptUUID,
+		expectedError:       "bad Request: sem:guid 1a96ee7a-a4af-3a56-852c-60420b0b8da6 does not match the @id of concept 20db1bd6-59f9-4404-adb5-3165a448f8b0",
+		expectedMismatches:  1,
+	}
+	preferGUID := testStruct{testName: "preferGUID", policy: GUIDMismatchPreferGUID, guid: guid, expectedConceptUUID: guid, expectedMismatches: 1}
+	preferInvalidGUID := testStruct{testName: "preferInvalidGUID", policy: GUIDMismatchPreferGUID, guid: "Essex", expectedConceptUUID: conceptUUID, expectedMismatches: 1}
+
+	testScenarios := []testStruct{matching, warn, reject, preferGUID, preferInvalidGUID}
+
+	for _, scenario := range testScenarios {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithGUIDMismatchPolicy(scenario.policy))
+		mismatchesBefore := metrics.GetOrRegisterCounter(guidMismatchMetric, metrics.DefaultRegistry).Count()
+
+		payload := `{"@id": "http://www.ft.com/thing/` + conceptUUID + `", "@type": ["http://www.ft.com/ontology/product/Brand"], "sem:guid": [{"@value": "` + scenario.guid + `"}], "http://www.ft.com/ontology/factsetIdentifier": [{"@value": "000D63-E"}]}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, uuid, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_guid")
+		assert.Equal(t, scenario.expectedConceptUUID, uuid, "Scenario: "+scenario.testName+" failed")
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, SemanticallyIncorrect, updateStatus, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, ErrCodeGUIDMismatch, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+		} else {
+			assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedConceptUUID, uppConcordance.ConceptUUID, "Scenario: "+scenario.testName+" failed")
+		}
+		mismatches := metrics.GetOrRegisterCounter(guidMismatchMetric, metrics.DefaultRegistry).Count() - mismatchesBefore
+		assert.Equal(t, scenario.expectedMismatches, mismatches, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestDeletedStatuses(t *testing.T) {
+	type testStruct struct {
+		testName             string
+		opts                 []TransformerOption
+		status               string
+		identifiers          string
+		expectedConcordedIDs []ConcordedID
+		expectedDeletes      int64
+	}
+
+	factsetID := `"http://www.ft.com/ontology/factsetIdentifier": [{"@value": "000D63-E"}]`
+	concordedFactset := []ConcordedID{{Authority: ConcordanceAuthorityFactset, AuthorityValue: "000D63-E", UUID: "8d3aba95-02d9-3802-afc0-b99bb9b1139e"}}
+
+	deprecatedLiteral := testStruct{testName: "deprecatedLiteral", status: `"Deprecated"`, identifiers: factsetID, expectedConcordedIDs: []ConcordedID{}, expectedDeletes: 1}
+	obsoleteReference := testStruct{testName: "obsoleteReference", status: `{"@id": "http://www.smartlogic.com/2014/08/semaphore-core#Obsolete"}`, identifiers: factsetID, expectedConcordedIDs: []ConcordedID{}, expectedDeletes: 1}
+	invalidIdentifiersAreNotValidated := testStruct{
+		testName:             "invalidIdentifiersAreNotValidated",
+		status:               `"deprecated"`,
+		identifiers:          `"http://www.ft.com/ontology/factsetIdentifier": [{"@value": "000D63-A"}]`,
+		expectedConcordedIDs: []ConcordedID{},
+		expectedDeletes:      1,
+	}
+	approved := testStruct{testName: "approved", status: `"Approved"`, identifiers: factsetID, expectedConcordedIDs: concordedFactset}
+	configuredStatus := testStruct{testName: "configuredStatus", opts: []TransformerOption{WithDeletedStatuses(" Retired ")}, status: `"retired"`, identifiers: factsetID, expectedConcordedIDs: []ConcordedID{}, expectedDeletes: 1}
+	defaultStatusNotConfigured := testStruct{testName: "defaultStatusNotConfigured", opts: []TransformerOption{WithDeletedStatuses("retired")}, status: `"deprecated"`, identifiers: factsetID, expectedConcordedIDs: concordedFactset}
+	noDeletedStatus := testStruct{testName: "noDeletedStatus", opts: []TransformerOption{WithDeletedStatuses()}, status: `"obsolete"`, identifiers: factsetID, expectedConcordedIDs: concordedFactset}
+
+	testScenarios := []testStruct{deprecatedLiteral, obsoleteReference, invalidIdentifiersAreNotValidated, approved, configuredStatus, defaultStatusNotConfigured, noDeletedStatus}
+
+	for _, scenario := range testScenarios {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), scenario.opts...)
+		deletesBefore := metrics.GetOrRegisterCounter(statusDeletesMetric, metrics.DefaultRegistry).Count()
+
+		payload := `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/product/Brand"], "sem:status": [` + scenario.status + `], ` + scenario.identifiers + `}]}`
+		var concepts ConceptData
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concepts), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concepts.Concepts[0], "tid_status")
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, ValidConcept, updateStatus, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+		deletes := metrics.GetOrRegisterCounter(statusDeletesMetric, metrics.DefaultRegistry).Count() - deletesBefore
+		assert.Equal(t, scenario.expectedDeletes, deletes, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestParseGUIDMismatchPolicy(t *testing.T) {
+	for _, value := range []string{"warn", "reject", "prefer-guid"} {
+		policy, err := ParseGUIDMismatchPolicy(value)
+		assert.NoError(t, err, "Policy: "+value+" failed")
+		assert.Equal(t, GUIDMismatchPolicy(value), policy, "Policy: "+value+" failed")
+	}
+	_, err := ParseGUIDMismatchPolicy("prefer-id")
+	assert.EqualError(t, err, `unknown guid mismatch policy "prefer-id"`)
+}
@@ -5,16 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"regexp"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
-	"github.com/pborman/uuid"
-
-	uuidUtils "github.com/Financial-Times/uuid-utils-go"
+	"github.com/rcrowley/go-metrics"
 )
 
 var uuidMatcher = regexp.MustCompile(`^[\da-f]{8}-[\da-f]{4}-[\da-f]{4}-[\da-f]{4}-[\da-f]{12}$`)
@@ -27,8 +24,14 @@ const (
 	ConcordanceAuthorityDbpedia         = "DBPedia"
 	ConcordanceAuthorityGeonames        = "Geonames"
 	ConcordanceAuthorityWikidata        = "Wikidata"
+	ConcordanceAuthorityLEI             = "LEI"
+	ConcordanceAuthorityFIGI            = "FIGI"
+	ConcordanceAuthorityCompaniesHouse  = "CompaniesHouse"
 	ConcordanceAuthoritySmartlogic      = "Smartlogic"
 	ConcordanceAuthorityManagedLocation = "ManagedLocation"
+	ConcordanceAuthorityISO31661Alpha2  = "ISO-3166-1-alpha-2"
+	ConcordanceAuthorityISO31661Alpha3  = "ISO-3166-1-alpha-3"
+	ConcordanceAuthorityISO31662        = "ISO-3166-2"
 
 	ThingURIPrefix           = "http://www.ft.com/thing/"
 	LocationURIPrefix        = "http://www.ft.com/ontology/managedlocation/"
@@ -39,369 +42,434 @@ const (
 	InternalError
 	ServiceUnavailable
 	NoContent
+	NotModified
 
 	alertTagConceptTypeNotAllowed = "SmartlogicConcordanceTransformerConceptTypeNotAllowed"
+	alertTagGUIDMismatch          = "SmartlogicConcordanceTransformerGuidMismatch"
 )
 
 var (
-	notAllowedConceptTypes = [...]string{
-		"skos:Concept",
-	}
-
 	errConceptTypeNotAllowed = errors.New("concept type not allowed")
+	errMissingGraph          = &ConceptError{Code: ErrCodeMissingGraph, Message: "invalid Request Json: Missing/invalid @graph field"}
 )
 
 type TransformerService struct {
-	topic         string
-	writerAddress string
-	httpClient    httpClient
-	log           *logger.UPPLogger
+	topic                   string
+	writerAddress           string
+	httpClient              httpClient
+	sinks                   []ConcordanceSink
+	hashStore               ConcordanceHashStore
+	conceptLocks            *conceptLocks
+	authorities             *AuthorityRegistry
+	knownMerges             *mergeIndex
+	editorialDbpedia        bool
+	conceptTypes            *ConceptTypePolicies
+	canonicalisationMode    CanonicalisationMode
+	duplicatePolicy         DuplicatePolicy
+	guidMismatchPolicy      GUIDMismatchPolicy
+	deletedStatuses         []string
+	tmeTaxonomies           *TmeTaxonomies
+	collectValidationErrors bool
+	retryPolicy             RetryPolicy
+	sleep                   func(time.Duration)
+	log                     *logger.UPPLogger
 }
 
+// TransformerOption configures optional behaviour of the TransformerService.
+type TransformerOption func(ts *TransformerService)
+
 type httpClient interface {
 	Do(req *http.Request) (resp *http.Response, err error)
 }
 
-func NewTransformerService(topic string, writerAddress string, httpClient httpClient, log *logger.UPPLogger) TransformerService {
-	return TransformerService{
-		topic:         topic,
-		writerAddress: writerAddress,
-		httpClient:    httpClient,
-		log:           log,
+func NewTransformerService(topic string, writerAddress string, httpClient httpClient, log *logger.UPPLogger, opts ...TransformerOption) TransformerService {
+	ts := TransformerService{
+		topic:                topic,
+		writerAddress:        writerAddress,
+		httpClient:           httpClient,
+		sinks:                []ConcordanceSink{NewHTTPSink(writerAddress, httpClient, log)},
+		conceptTypes:         DefaultConceptTypePolicies(),
+		knownMerges:          newMergeIndex(),
+		conceptLocks:         newConceptLocks(),
+		canonicalisationMode: CanonicalisationCompat,
+		guidMismatchPolicy:   GUIDMismatchWarn,
+		deletedStatuses:      defaultDeletedStatuses,
+		retryPolicy:          noRetryPolicy,
+		sleep:                time.Sleep,
+		log:                  log,
+	}
+	for _, opt := range opts {
+		opt(&ts)
+	}
+	if ts.authorities == nil {
+		ts.authorities = DefaultAuthorityRegistry()
+	}
+	if ts.editorialDbpedia {
+		ts.authorities = ts.authorities.enabled(conceptModelEditorial, editorialDbpediaPredicate)
+	}
+	return ts
+}
+
+// WithSinks replaces the default concordances-rw-neo4j writer with the given sinks.
+func WithSinks(sinks ...ConcordanceSink) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.sinks = sinks
+	}
+}
+
+// WithAuthorityRegistry replaces the built-in mapping of identifier predicates to concordance authorities.
+func WithAuthorityRegistry(registry *AuthorityRegistry) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.authorities = registry
+	}
+}
+
+// WithEditorialDbpedia sets whether the DBpedia identifiers of editorial concepts are concorded, which are otherwise
+// ignored. It applies to a registry given with WithAuthorityRegistry too, unless its authority config maps or
+// disables the predicate itself.
+func WithEditorialDbpedia(enabled bool) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.editorialDbpedia = enabled
 	}
 }
 
-func (ts *TransformerService) handleConcordanceEvent(msgBody string, tid string) error {
+func (s status) String() string {
+	switch s {
+	case NotFound:
+		return "NotFound"
+	case SyntacticallyIncorrect:
+		return "SyntacticallyIncorrect"
+	case SemanticallyIncorrect:
+		return "SemanticallyIncorrect"
+	case ValidConcept:
+		return "ValidConcept"
+	case InternalError:
+		return "InternalError"
+	case ServiceUnavailable:
+		return "ServiceUnavailable"
+	case NoContent:
+		return "NoContent"
+	case NotModified:
+		return "NotModified"
+	default:
+		return "Unknown"
+	}
+}
+
+func (ts *TransformerService) handleConcordanceEvent(msgBody string, tid string, force bool) (status, error) {
 	ts.log.WithField("transaction_id", tid).Debug("Processing message with body: " + msgBody)
 	var smartLogicConceptPayload = ConceptData{}
 	decoder := json.NewDecoder(bytes.NewBufferString(msgBody))
 	err := decoder.Decode(&smartLogicConceptPayload)
 	if err != nil {
 		ts.log.WithError(err).WithField("transaction_id", tid).Error("Failed to decode Kafka payload")
-		return err
+		return SyntacticallyIncorrect, err
 	}
 
-	_, conceptUUID, uppConcordance, err := convertToUppConcordance(smartLogicConceptPayload, tid, ts.log)
+	results, err := ts.convertToUppConcordances(smartLogicConceptPayload, tid)
 	if err != nil {
-		return err
+		return SemanticallyIncorrect, err
 	}
-	_, err = ts.makeRelevantRequest(conceptUUID, uppConcordance, tid)
-	if err != nil {
-		return err
+	for i, result := range results {
+		if result.err != nil {
+			continue
+		}
+		results[i].status, results[i].err = ts.sendIfModified(result.conceptUUID, result.uppConcordance, tid, force, ts.makeRelevantRequestWithRetry)
+		if results[i].err == nil {
+			ts.knownMerges.record(result.conceptUUID, result.uppConcordance)
+		}
+		if results[i].err == nil && results[i].status != NotModified {
+			ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": result.conceptUUID}).Info("Forwarded concordance record to rw")
+		}
 	}
-	ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Info("Forwarded concordance record to rw")
-	return nil
+	return combineConceptResults(results)
 }
 
-func convertToUppConcordance(Concepts ConceptData, tid string, log *logger.UPPLogger) (status, string, UppConcordance, error) {
-	if len(Concepts.Concepts) == 0 {
-		err := errors.New("invalid Request Json: Missing/invalid @graph field")
-		log.WithField("transaction_id", tid).Error(err)
-		return SemanticallyIncorrect, "", UppConcordance{}, err
-	}
-	if len(Concepts.Concepts) > 1 {
-		err := errors.New("invalid Request Json: More than 1 concept in smartlogic concept payload which is currently not supported")
-		log.WithField("transaction_id", tid).Error(err)
-		return SemanticallyIncorrect, "", UppConcordance{}, err
+// conceptResult is the outcome of transforming, and possibly sending, a single concept of a Smartlogic payload.
+type conceptResult struct {
+	status         status
+	conceptUUID    string
+	uppConcordance UppConcordance
+	err            error
+}
+
+// convertToUppConcordances transforms every concept in the @graph independently, so a concept failing
+// doesn't prevent its siblings from being transformed.
+func (ts *TransformerService) convertToUppConcordances(concepts ConceptData, tid string) ([]conceptResult, error) {
+	if len(concepts.Concepts) == 0 {
+		ts.log.WithField("transaction_id", tid).Error(errMissingGraph)
+		return nil, errMissingGraph
+	}
+
+	results := make([]conceptResult, 0, len(concepts.Concepts))
+	for _, concept := range concepts.Concepts {
+		updateStatus, conceptUUID, uppConcordance, err := ts.convertToUppConcordance(concept, tid)
+		results = append(results, conceptResult{
+			status:         updateStatus,
+			conceptUUID:    conceptUUID,
+			uppConcordance: uppConcordance,
+			err:            err,
+		})
+	}
+	ts.rejectMergeCycles(results, tid)
+	return results, nil
+}
+
+// combineConceptResults reports the outcome of a whole payload: the status of its first concept when all of them
+// succeeded, otherwise the status of the first failure and the errors of every failing concept.
+func combineConceptResults(results []conceptResult) (status, error) {
+	if len(results) == 1 {
+		return results[0].status, results[0].err
 	}
 
-	concept := Concepts.Concepts[0]
+	var failures []error
+	firstFailure := -1
+	for i, result := range results {
+		if result.err == nil {
+			continue
+		}
+		if firstFailure < 0 {
+			firstFailure = i
+		}
+		conceptUUID := result.conceptUUID
+		if conceptUUID == "" {
+			conceptUUID = fmt.Sprintf("at position %d", i)
+		}
+		failures = append(failures, fmt.Errorf("concept %s: %w", conceptUUID, result.err))
+	}
+	if firstFailure < 0 {
+		return results[0].status, nil
+	}
+	return results[firstFailure].status, errors.Join(failures...)
+}
 
+func (ts *TransformerService) convertToUppConcordance(concept Concept, tid string) (status, string, UppConcordance, error) {
 	conceptUUID, uppAuthority := extractUUIDAndConcordanceAuthority(concept.ID)
 	if conceptUUID == "" {
-		err := errors.New("invalid Request Json: Missing/invalid @id field")
-		log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
+		err := &ConceptError{Code: ErrCodeInvalidConceptID, Value: concept.ID, Message: "invalid Request Json: Missing/invalid @id field"}
+		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
 		return SemanticallyIncorrect, conceptUUID, UppConcordance{}, err
 	}
 
+	conceptUUID, guidErr := ts.checkGUID(concept, conceptUUID, tid)
+	if guidErr != nil {
+		return SemanticallyIncorrect, conceptUUID, UppConcordance{}, guidErr
+	}
+
+	if uppConcordance, deleted := ts.deleteForStatus(concept, conceptUUID, uppAuthority, tid); deleted {
+		return ValidConcept, conceptUUID, uppConcordance, nil
+	}
+
 	if len(concept.Types) == 0 {
-		err := fmt.Errorf("bad Request: Type has not been set for concept: %s)", conceptUUID)
-		log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
+		err := &ConceptError{Code: ErrCodeMissingConceptType, ConceptUUID: conceptUUID, Message: fmt.Sprintf("bad Request: Type has not been set for concept: %s)", conceptUUID)}
+		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
 		return SyntacticallyIncorrect, conceptUUID, UppConcordance{}, err
 	}
 
-	conceptType := concept.Types[0]
+	conceptTypes := ts.conceptTypes.ordered(concept.Types)
+	uppConceptType := shortFormType(conceptTypes[0])
 
-	for _, bannedConceptType := range notAllowedConceptTypes {
-		if conceptType != bannedConceptType {
+	rules := ts.authorities.rulesFor(concept.model(), "")
+	for _, conceptType := range conceptTypes {
+		policy := ts.conceptTypes.policyFor(conceptType)
+		updateStatus, err := policy.violation(concept, conceptType, conceptUUID, rules)
+		if err == nil {
 			continue
 		}
-		log.WithFields(map[string]interface{}{
+		logEntry := ts.log.WithFields(map[string]interface{}{
 			"transaction_id": tid,
 			"UUID":           conceptUUID,
-			"concept_type":   bannedConceptType,
-			"alert_tag":      alertTagConceptTypeNotAllowed,
-		}).Error(errConceptTypeNotAllowed)
-		return SemanticallyIncorrect, conceptUUID, UppConcordance{}, errConceptTypeNotAllowed
+			"concept_type":   conceptType,
+			"authority":      err.Authority,
+			"on_violation":   policy.OnViolation,
+		})
+		if err.Code == ErrCodeConceptTypeNotAllowed {
+			logEntry = logEntry.WithField("alert_tag", alertTagConceptTypeNotAllowed)
+		}
+		switch policy.OnViolation {
+		case violationDelete:
+			logEntry.Warn(fmt.Sprintf("%s; deleting its concordances", err))
+			return ValidConcept, conceptUUID, UppConcordance{ConceptUUID: conceptUUID, Authority: uppAuthority, ConcordedIds: []ConcordedID{}, ConceptType: uppConceptType}, nil
+		case violationStrip:
+			logEntry.Warn(fmt.Sprintf("%s; skipping the identifiers it may not carry", err))
+			rules = policy.permitted(rules)
+			if !policy.permits(ConcordanceAuthoritySmartlogic) {
+				concept.Merged = nil
+			}
+		default:
+			logEntry.Error(err)
+			return updateStatus, conceptUUID, UppConcordance{}, err
+		}
 	}
 
-	shortFormType := conceptType[strings.LastIndex(conceptType, "/")+1:]
-	if (shortFormType == "Membership" || shortFormType == "MembershipRole") && len(concept.TmeIdentifiers()) > 0 {
-		err := fmt.Errorf("bad Request: Concept type %s does not support concordance", shortFormType)
-		log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
+	if predicate := ts.authorities.unresolvedPredicate(concept); predicate != "" {
+		err := &ConceptError{
+			Code:        ErrCodeUnresolvedPredicate,
+			ConceptUUID: conceptUUID,
+			Value:       predicate,
+			Message:     fmt.Sprintf("bad Request: Predicate %s could not be expanded with the @context of the payload", predicate),
+		}
+		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID, "alert_tag": "ConceptLoadingInvalidConcordance"}).Error(err)
 		return SyntacticallyIncorrect, conceptUUID, UppConcordance{}, err
 	}
 
 	//replacing with nil slice breaks tests
-	concordances := []ConcordedID{}
-
-	concordances, err := appendTmeConcordances(concordances, concept, conceptUUID, tid, log)
-
-	if err != nil {
-		return SyntacticallyIncorrect, conceptUUID, UppConcordance{}, err
-	}
-
-	concordances, err = appendFactsetConcordances(concordances, concept, conceptUUID, tid, log)
-
-	if err != nil {
-		return SyntacticallyIncorrect, conceptUUID, UppConcordance{}, err
-	}
-
-	concordances, err = appendLocationConcordances(concordances, concept.DbpediaIdentifiers(), conceptUUID, ConcordanceAuthorityDbpedia, tid, log)
-	if err != nil {
-		return SyntacticallyIncorrect, conceptUUID, UppConcordance{}, err
-	}
-
-	concordances, err = appendLocationConcordances(concordances, concept.GeonamesIdentifiers(), conceptUUID, ConcordanceAuthorityGeonames, tid, log)
-	if err != nil {
-		return SyntacticallyIncorrect, conceptUUID, UppConcordance{}, err
-	}
-
-	concordances, err = appendLocationConcordances(concordances, concept.WikidataIdentifiers(), conceptUUID, ConcordanceAuthorityWikidata, tid, log)
+	concordances, duplicates, err := ts.appendConcordances([]ConcordedID{}, concept, uppConceptType, rules, conceptUUID, tid)
 	if err != nil {
 		return SyntacticallyIncorrect, conceptUUID, UppConcordance{}, err
 	}
+	sortConcordedIDs(concordances)
 
 	uppConcordance := UppConcordance{
 		ConceptUUID:  conceptUUID,
 		Authority:    uppAuthority,
 		ConcordedIds: concordances,
+		Duplicates:   duplicates,
+		ConceptType:  uppConceptType,
 	}
-	log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Debugf("Concordance record is %s", uppConcordance)
+	ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Debugf("Concordance record is %s", uppConcordance)
 
 	return ValidConcept, conceptUUID, uppConcordance, nil
 }
 
-func appendTmeConcordances(concordances []ConcordedID, concept Concept, conceptUUID string, tid string, log *logger.UPPLogger) ([]ConcordedID, error) {
-	for _, id := range concept.TmeIdentifiers() {
-		uuidFromTmeID, err := validateTmeIDAndConvertToUUID(id.Value)
-		if conceptUUID == uuidFromTmeID {
-			err := errors.New("bad Request: Payload from smartlogic has a smartlogic uuid that is the same as the uuid generated from the TME id")
-			log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
-			return nil, err
-		}
-		if err != nil {
-			log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID, "alert_tag": "ConceptLoadingInvalidConcordance"}).Error(err)
-			return nil, err
-		}
-		concordedID := ConcordedID{
-			Authority:      ConcordanceAuthorityTme,
-			AuthorityValue: id.Value,
-			UUID:           uuidFromTmeID,
-		}
-		if len(concordances) > 0 {
-			for _, cid := range concordances {
-				if cid.UUID == uuidFromTmeID {
-					err := errors.New("bad Request: Payload from smartlogic contains duplicate TME id values")
-					log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
-					return nil, err
-				}
+// appendConcordances converts the identifiers of the concept into concordances, following the authority rules given,
+// and concords the concepts merged into it.
+// Invalid identifiers are returned as a ValidationError holding the first issue, or every issue when collecting them.
+// Identifiers repeating a concordance are rejected or returned as duplicates, following the duplicate policy of
+// their authority.
+func (ts *TransformerService) appendConcordances(concordances []ConcordedID, concept Concept, conceptType string, rules []authorityRule, conceptUUID string, tid string) ([]ConcordedID, []DuplicateIdentifier, error) {
+	var issues []ValidationIssue
+	var duplicates []DuplicateIdentifier
+	failed := func(issue ValidationIssue) bool {
+		ts.logValidationIssue(issue, conceptUUID, tid)
+		issues = append(issues, issue)
+		return !ts.collectValidationErrors
+	}
+
+	for _, rule := range rules {
+		ids, malformed := concept.identifiers(rule.Predicate)
+		for _, issue := range malformed {
+			issue.Authority = rule.Authority
+			if failed(issue) {
+				return nil, nil, &ValidationError{ConceptUUID: conceptUUID, Issues: issues}
 			}
-			concordances = append(concordances, concordedID)
-		} else {
-			concordances = append(concordances, concordedID)
 		}
-	}
-
-	return concordances, nil
-}
+		for _, id := range ids {
+			if rule.SkipBlank && len(strings.TrimSpace(id.Value)) == 0 {
+				ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID, "path": id.path}).Warn(fmt.Sprintf("Payload from Smartlogic contains one or more empty %v values. Skipping it", rule.Authority))
+				continue
+			}
 
-func appendFactsetConcordances(concordances []ConcordedID, concept Concept, conceptUUID string, tid string, log *logger.UPPLogger) ([]ConcordedID, error) {
-	for _, id := range concept.FactsetIdentifiers() {
-		uuidFromFactsetID, err := validateFactsetIDAndConvertToUUID(id.Value)
-		if conceptUUID == uuidFromFactsetID {
-			err := errors.New("bad Request: Payload from smartlogic has a smartlogic uuid that is the same as the uuid generated from the FACTSET id")
-			log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
-			return nil, err
-		}
-		if err != nil {
-			log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID, "alert_tag": "ConceptLoadingInvalidConcordance"}).Error(err)
-			return nil, err
-		}
-		concordedID := ConcordedID{
-			Authority:      ConcordanceAuthorityFactset,
-			AuthorityValue: id.Value,
-			UUID:           uuidFromFactsetID,
-		}
-		if len(concordances) > 0 {
-			for _, cid := range concordances {
-				if cid.UUID == uuidFromFactsetID {
-					err := errors.New("bad Request: Payload from smartlogic contains duplicate FACTSET id values")
-					log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
-					return nil, err
-				}
+			issue := ValidationIssue{Authority: rule.Authority, Value: id.Value, Path: id.path}
+			value := ts.canonicalValue(rule, id, conceptUUID, tid)
+			uuidFromID, err := rule.convert(value)
+			if err == nil && rule.Validation == validationTme {
+				err = ts.checkTmeTaxonomy(value, conceptType, conceptUUID, tid)
+			}
+			switch {
+			case err != nil && rule.Lenient:
+				issue.Code, issue.Reason = invalidIdentifierCode(rule.Authority), err.Error()
+				ts.validationIssueLogEntry(issue, conceptUUID, tid).Warn(issue.Reason + "; skipping it")
+				continue
+			case err != nil:
+				issue.Code, issue.Reason = invalidIdentifierCode(rule.Authority), err.Error()
+			case conceptUUID == uuidFromID:
+				issue.Code = ErrCodeUUIDCollision
+				issue.Reason = fmt.Sprintf("bad Request: Payload from smartlogic has a smartlogic uuid that is the same as the uuid generated from the %s id", rule.Authority)
+			case concordancesContainValue(concordances, uuidFromID) && ts.duplicatePolicyFor(rule) != DuplicatesReject:
+				duplicate := DuplicateIdentifier{Authority: rule.Authority, AuthorityValue: value, UUID: uuidFromID, Path: id.path}
+				duplicates = append(duplicates, ts.deduplicate(rule, duplicate, conceptUUID, tid))
+				continue
+			case concordancesContainValue(concordances, uuidFromID):
+				metrics.GetOrRegisterCounter(duplicatesRejectedMetric, metrics.DefaultRegistry).Inc(1)
+				issue.Code = ErrCodeDuplicateIdentifier
+				issue.Reason = fmt.Sprintf("bad Request: Payload from smartlogic contains duplicate %s id values", rule.Authority)
+			default:
+				concordances = append(concordances, ConcordedID{
+					Authority:      rule.Authority,
+					AuthorityValue: value,
+					UUID:           uuidFromID,
+				})
+				continue
+			}
+			if failed(issue) {
+				return nil, nil, &ValidationError{ConceptUUID: conceptUUID, Issues: issues}
 			}
-			concordances = append(concordances, concordedID)
-		} else {
-			concordances = append(concordances, concordedID)
 		}
 	}
 
-	return concordances, nil
-}
-
-func appendLocationConcordances(concordances []ConcordedID, conceptIdentifiers []LocationType, conceptUUID string, authority string, tid string, log *logger.UPPLogger) ([]ConcordedID, error) {
-	for _, id := range conceptIdentifiers {
-		if len(strings.TrimSpace(id.Value)) == 0 {
-			log.WithFields(map[string]interface{}{"transaction_id": tid, "uuid": conceptUUID}).Warn(fmt.Sprintf("Payload from Smartlogic contains one or more empty %v values. Skipping it", authority))
-			continue
-		}
-
-		uuidFromConceptIdentifier := convertToUUID(id.Value)
-		if conceptUUID == uuidFromConceptIdentifier {
-			err := fmt.Errorf("bad Request: Payload from Smartlogic has a Smartlogic uuid that is the same as the uuid generated from %v id", authority)
-			log.WithFields(map[string]interface{}{"transaction_id": tid, "uuid": conceptUUID}).Error(err)
-			return nil, err
-		}
-		if concordancesContainValue(concordances, uuidFromConceptIdentifier) {
-			log.WithFields(map[string]interface{}{"transaction_id": tid, "uuid": conceptUUID}).Warn(fmt.Sprintf("Payload from Smartlogic contains duplicate %v values. Skipping it", authority))
-			continue
-		}
-
-		concordedID := ConcordedID{
-			Authority:      authority,
-			AuthorityValue: id.Value,
-			UUID:           uuidFromConceptIdentifier,
-		}
-		if len(concordances) > 0 {
-			for _, cid := range concordances {
-				if cid.UUID == uuidFromConceptIdentifier {
-					err := errors.New("Bad Request: Payload from smartlogic contains duplicate " + authority + " id values")
-					log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": conceptUUID}).Error(err)
-					return nil, err
-				}
-			}
-			concordances = append(concordances, concordedID)
-		} else {
-			concordances = append(concordances, concordedID)
+	concordances, mergeIssues := ts.appendMergedConcepts(concordances, concept, conceptUUID, tid)
+	for _, issue := range mergeIssues {
+		if failed(issue) {
+			return nil, nil, &ValidationError{ConceptUUID: conceptUUID, Issues: issues}
 		}
 	}
 
-	return concordances, nil
-}
-
-func validateTmeIDAndConvertToUUID(tmeID string) (string, error) {
-	subStrings := strings.Split(tmeID, "-")
-	if len(subStrings) != 2 || !validateSubstrings(subStrings) {
-		return "", errors.New("Bad Request: Concordance id " + tmeID + " is not a valid TME Id")
+	if len(issues) > 0 {
+		return nil, nil, &ValidationError{ConceptUUID: conceptUUID, Issues: issues}
 	}
-	return uuid.NewMD5(uuid.UUID{}, []byte(tmeID)).String(), nil
+	return concordances, duplicates, nil
 }
 
-func validateFactsetIDAndConvertToUUID(factsetID string) (string, error) {
-	if len(factsetID) != 8 || factsetID[0] != '0' || factsetID[6:8] != "-E" {
-		return "", errors.New("Bad Request: Concordance id " + factsetID + " is not a valid FACTSET Id")
-	}
-	return uuidUtils.DeriveFactsetUUID(factsetID), nil
+func (ts *TransformerService) logValidationIssue(issue ValidationIssue, conceptUUID string, tid string) {
+	ts.validationIssueLogEntry(issue, conceptUUID, tid).Error(issue.Reason)
 }
 
-func convertToUUID(id string) string {
-	return uuid.NewMD5(uuid.UUID{}, []byte(id)).String()
-}
-
-func validateSubstrings(subStrings []string) bool {
-	for _, sub := range subStrings {
-		if sub == "" {
-			return false
-		}
-	}
-	return true
+func (ts *TransformerService) validationIssueLogEntry(issue ValidationIssue, conceptUUID string, tid string) *logger.LogEntry {
+	return ts.log.WithFields(map[string]interface{}{
+		"transaction_id": tid,
+		"UUID":           conceptUUID,
+		"error_code":     issue.Code,
+		"authority":      issue.Authority,
+		"value":          issue.Value,
+		"path":           issue.Path,
+		"alert_tag":      "ConceptLoadingInvalidConcordance",
+	})
 }
 
 func (ts *TransformerService) makeRelevantRequest(uuid string, uppConcordance UppConcordance, tid string) (status, error) {
-	var err error
-	var reqStatus status
-	if len(uppConcordance.ConcordedIds) > 0 {
-		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Infof("Concordance record is: %s; forwarding request to writer", uppConcordance)
-		reqStatus, err = ts.makeWriteRequest(uuid, uppConcordance, tid)
-	} else {
-		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Debug("No concordance found; making delete request")
-		reqStatus, err = ts.makeDeleteRequest(uuid, tid)
-	}
-
-	return reqStatus, err
+	resp, err := ts.sendToSinks(uuid, uppConcordance, tid, &sinkDelivery{})
+	return resp.status, err
 }
 
-func (ts *TransformerService) makeWriteRequest(uuid string, uppConcordance UppConcordance, tid string) (status, error) {
-	reqURL := ts.writerAddress + "branches/" + uuid
-	concordedJSON, err := json.Marshal(uppConcordance)
-	if err != nil {
-		ts.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Bad Request: Could not unmarshall concordance json")
-		return SyntacticallyIncorrect, err
-	}
-
-	request, err := http.NewRequest("PUT", reqURL, strings.NewReader(string(concordedJSON)))
-	if err != nil {
-		ts.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Internal Error: Failed to create GET request to " + reqURL + " with body " + string(concordedJSON))
-		return InternalError, err
-	}
-	request.ContentLength = -1
-	request.Header.Set("X-Request-Id", tid)
-
-	resp, err := ts.httpClient.Do(request)
-	if err != nil {
-		ts.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Service Unavailable: Get request to writer resulted in error")
-		return ServiceUnavailable, err
-	}
+// sinkDelivery records how far a concordance record got through the sinks, so a retry resumes at the sink which
+// failed rather than sending the record again to those which already accepted it.
+type sinkDelivery struct {
+	responses []sinkResponse
+}
 
-	defer func(body io.ReadCloser) {
-		err := body.Close()
+// sendToSinks writes the concordance record to every configured sink the delivery hasn't reached yet, or deletes it
+// when there are no concordances. It stops at the first sink failing and otherwise reports the response of the first
+// sink.
+func (ts *TransformerService) sendToSinks(uuid string, uppConcordance UppConcordance, tid string, delivery *sinkDelivery) (sinkResponse, error) {
+	for _, sink := range ts.sinks[len(delivery.responses):] {
+		var resp sinkResponse
+		var err error
+		if len(uppConcordance.ConcordedIds) > 0 {
+			ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid, "sink": sink.Name()}).Infof("Concordance record is: %s; forwarding request to writer", uppConcordance)
+			resp, err = sink.Write(uuid, uppConcordance, tid)
+		} else {
+			ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid, "sink": sink.Name()}).Debug("No concordance found; making delete request")
+			resp, err = sink.Delete(uuid, tid)
+		}
 		if err != nil {
-			ts.log.WithError(err).Info("Could not close body")
+			return resp, err
 		}
-	}(resp.Body)
-
-	if resp.StatusCode != 200 && resp.StatusCode != 201 && resp.StatusCode != 304 {
-		err := errors.New("Internal Error: Get request to writer returned unexpected status: " + strconv.Itoa(resp.StatusCode))
-		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid, "status": resp.StatusCode}).Error(err)
-		return InternalError, err
+		delivery.responses = append(delivery.responses, resp)
 	}
-
-	return ValidConcept, nil
-}
-
-func (ts *TransformerService) makeDeleteRequest(uuid string, tid string) (status, error) {
-	reqURL := ts.writerAddress + "branches/" + uuid
-	request, err := http.NewRequest("DELETE", reqURL, strings.NewReader(""))
-	if err != nil {
-		ts.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Internal Error: Failed to create DELETE request to " + reqURL)
-		return InternalError, err
-	}
-	request.ContentLength = -1
-	request.Header.Set("X-Request-Id", tid)
-
-	resp, err := ts.httpClient.Do(request)
-	if err != nil {
-		ts.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Service Unavailable: Delete request to writer resulted in error")
-		return ServiceUnavailable, err
+	if len(delivery.responses) == 0 {
+		return sinkResponse{status: InternalError}, errors.New("Internal Error: No concordance sink configured")
 	}
+	return delivery.responses[0], nil
+}
 
-	defer func(body io.ReadCloser) {
-		err := body.Close()
-		if err != nil {
-			ts.log.WithError(err).Info("Could not close body")
+func (ts *TransformerService) hasHTTPSink() bool {
+	for _, sink := range ts.sinks {
+		if _, ok := sink.(*httpSink); ok {
+			return true
 		}
-	}(resp.Body)
-
-	if resp.StatusCode != 204 && resp.StatusCode != 404 {
-		err := errors.New("Internal Error: Delete request to writer returned unexpected status: " + strconv.Itoa(resp.StatusCode))
-		ts.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid, "status": resp.StatusCode}).Error(err)
-		return InternalError, err
-	}
-	if resp.StatusCode == 204 {
-		return NoContent, nil
 	}
-	return NotFound, nil
+	return false
 }
 
 func extractUUIDAndConcordanceAuthority(url string) (string, string) {
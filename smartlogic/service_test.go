@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"testing"
 
+	uuidUtils "github.com/Financial-Times/uuid-utils-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -61,8 +63,9 @@ func TestValidateTmeIdAndConvertToUuid(t *testing.T) {
 
 	testScenarios := []testStruct{invalidTmeIDHasNoHyphen, invalidTmeIDHasNoTaxonomy, invalidTmeIDHasNoValue, invalidTmeIDHasTooManyParts, validTmeIDIsConverted}
 
+	tmeRule := DefaultAuthorityRegistry().rulesFor(conceptModelEditorial, ConcordanceAuthorityTme)[0]
 	for _, scenario := range testScenarios {
-		uuid, err := validateTmeIDAndConvertToUUID(scenario.tmeID)
+		uuid, err := tmeRule.convert(scenario.tmeID)
 		assert.Equal(t, scenario.expectedUUID, uuid, "Scenario: "+scenario.testName+" failed")
 		assert.Equal(t, scenario.expectedError, err, "Scenario: "+scenario.testName+" failed")
 	}
@@ -72,19 +75,47 @@ func TestValidateFactsetIdAndConvertToUuid(t *testing.T) {
 	type testStruct struct {
 		testName      string
 		factsetID     string
+		suffixes      []string
 		expectedUUID  string
 		expectedError error
 	}
 
-	invalidFactsetIDNoZeroPrefix := testStruct{testName: "invalidFactsetIdNoZeroPrefix", factsetID: "123456-E", expectedUUID: "", expectedError: errors.New("Bad Request: Concordance id 123456-E is not a valid FACTSET Id")}
-	invalidFactsetINoESuffix := testStruct{testName: "invalidFactsetINoESuffix", factsetID: "023456-A", expectedUUID: "", expectedError: errors.New("Bad Request: Concordance id 023456-A is not a valid FACTSET Id")}
-	invalidFactsetIDNoHyphenSuffix := testStruct{testName: "invalidFactsetIdNoHyphenSuffix", factsetID: "0123456E", expectedUUID: "", expectedError: errors.New("Bad Request: Concordance id 0123456E is not a valid FACTSET Id")}
+	invalidFactsetIDNoZeroPrefix := testStruct{testName: "invalidFactsetIdNoZeroPrefix", factsetID: "123456-E", expectedUUID: "", expectedError: errors.New("Bad Request: Concordance id 123456-E is not a valid FACTSET Id: entity identifiers start with 0")}
+	invalidFactsetINoESuffix := testStruct{testName: "invalidFactsetINoESuffix", factsetID: "023456-A", expectedUUID: "", expectedError: errors.New("Bad Request: Concordance id 023456-A is not a valid FACTSET Id: unknown suffix -A")}
+	invalidFactsetIDNoHyphenSuffix := testStruct{testName: "invalidFactsetIdNoHyphenSuffix", factsetID: "0123456E", expectedUUID: "", expectedError: errors.New("Bad Request: Concordance id 0123456E is not a valid FACTSET Id: expected six letters or digits, a hyphen and a suffix, e.g. 000D63-E")}
+	invalidFactsetIDLowercase := testStruct{testName: "invalidFactsetIdLowercase", factsetID: "000d63-E", expectedUUID: "", expectedError: errors.New("Bad Request: Concordance id 000d63-E is not a valid FACTSET Id: expected six letters or digits, a hyphen and a suffix, e.g. 000D63-E")}
 	validFactsetIDIsConverted := testStruct{testName: "validFactsetIdIsConverted", factsetID: "012345-E", expectedUUID: "949a7e7f-2516-30c0-9123-f866601ffbe4", expectedError: nil}
+	validSecurityIDIsConverted := testStruct{testName: "validSecurityIdIsConverted", factsetID: "DXVFL7-S", expectedUUID: uuidUtils.DeriveFactsetUUID("DXVFL7-S"), expectedError: nil}
+	validRegionalIDIsConverted := testStruct{testName: "validRegionalIdIsConverted", factsetID: "MH33D6-R", expectedUUID: uuidUtils.DeriveFactsetUUID("MH33D6-R"), expectedError: nil}
+	validListingIDIsConverted := testStruct{testName: "validListingIdIsConverted", factsetID: "QBRHVZ-L", expectedUUID: uuidUtils.DeriveFactsetUUID("QBRHVZ-L"), expectedError: nil}
+	suffixNotAllowed := testStruct{testName: "suffixNotAllowed", factsetID: "DXVFL7-S", suffixes: []string{"E"}, expectedUUID: "", expectedError: errors.New("Bad Request: Concordance id DXVFL7-S is not a valid FACTSET Id: security identifiers (-S) are not allowed")}
 
-	testScenarios := []testStruct{invalidFactsetIDNoZeroPrefix, invalidFactsetINoESuffix, invalidFactsetIDNoHyphenSuffix, validFactsetIDIsConverted}
+	testScenarios := []testStruct{
+		invalidFactsetIDNoZeroPrefix,
+		invalidFactsetINoESuffix,
+		invalidFactsetIDNoHyphenSuffix,
+		invalidFactsetIDLowercase,
+		validFactsetIDIsConverted,
+		validSecurityIDIsConverted,
+		validRegionalIDIsConverted,
+		validListingIDIsConverted,
+		suffixNotAllowed,
+	}
+
+	var factsetConfig AuthorityConfig
+	for _, config := range defaultAuthorityConfigs {
+		if config.Model == conceptModelEditorial && config.Authority == ConcordanceAuthorityFactset {
+			factsetConfig = config
+		}
+	}
+	assert.Equal(t, validationFactset, factsetConfig.Validation)
 
 	for _, scenario := range testScenarios {
-		uuid, err := validateFactsetIDAndConvertToUUID(scenario.factsetID)
+		config := factsetConfig
+		config.Suffixes = scenario.suffixes
+		factsetRule, err := newAuthorityRule(config)
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		uuid, err := factsetRule.convert(scenario.factsetID)
 		assert.Equal(t, scenario.expectedUUID, uuid, "Scenario: "+scenario.testName+" failed")
 		assert.Equal(t, scenario.expectedError, err, "Scenario: "+scenario.testName+" failed")
 	}
@@ -155,6 +186,10 @@ func TestConvertToUppConcordance(t *testing.T) {
 		Authority:   "Smartlogic",
 		ConcordedIds: []ConcordedID{
 			{
+				Authority:      ConcordanceAuthorityTme,
+				AuthorityValue: "ABCDEFGHIJKLMNOPQRSTUVWXYZ-0987654321",
+				UUID:           "e574b21d-9abc-3d82-a6c0-3e08c85181bf",
+			}, {
 				Authority:      ConcordanceAuthorityTme,
 				AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789",
 				UUID:           "e9f4525a-401f-3b23-a68e-e48f314cdce6",
@@ -166,10 +201,6 @@ func TestConvertToUppConcordance(t *testing.T) {
 				Authority:      ConcordanceAuthorityTme,
 				AuthorityValue: "abcdefghijklmnopqrstuvwxyz-0123456789",
 				UUID:           "e4bc4ac2-0637-3a27-86b1-9589fca6bf2c",
-			}, {
-				Authority:      ConcordanceAuthorityTme,
-				AuthorityValue: "ABCDEFGHIJKLMNOPQRSTUVWXYZ-0987654321",
-				UUID:           "e574b21d-9abc-3d82-a6c0-3e08c85181bf",
 			},
 		},
 	}
@@ -181,14 +212,14 @@ func TestConvertToUppConcordance(t *testing.T) {
 				Authority:      ConcordanceAuthorityFactset,
 				AuthorityValue: "000D63-E",
 				UUID:           "8d3aba95-02d9-3802-afc0-b99bb9b1139e",
-			}, {
-				Authority:      ConcordanceAuthorityFactset,
-				AuthorityValue: "023456-E",
-				UUID:           "3bc0ab41-c01f-3a0b-aa78-c76438080b52",
 			}, {
 				Authority:      ConcordanceAuthorityFactset,
 				AuthorityValue: "023411-E",
 				UUID:           "f777c5af-e0b2-34dc-9102-e346ca2d27aa",
+			}, {
+				Authority:      ConcordanceAuthorityFactset,
+				AuthorityValue: "023456-E",
+				UUID:           "3bc0ab41-c01f-3a0b-aa78-c76438080b52",
 			},
 		},
 	}
@@ -196,16 +227,16 @@ func TestConvertToUppConcordance(t *testing.T) {
 		ConceptUUID: testUUID,
 		Authority:   "ManagedLocation",
 		ConcordedIds: []ConcordedID{
-			{
-				Authority:      ConcordanceAuthorityTme,
-				AuthorityValue: "ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321",
-				UUID:           "83f63c7e-1641-3c7b-81e4-378ae3c6c2ad",
-			},
 			{
 				Authority:      ConcordanceAuthorityFactset,
 				AuthorityValue: "023456-E",
 				UUID:           "3bc0ab41-c01f-3a0b-aa78-c76438080b52",
 			},
+			{
+				Authority:      ConcordanceAuthorityTme,
+				AuthorityValue: "ZyXwVuTsRqPoNmLkJiHgFeDcBa-0987654321",
+				UUID:           "83f63c7e-1641-3c7b-81e4-378ae3c6c2ad",
+			},
 		},
 	}
 	locationsConcordance := UppConcordance{
@@ -213,10 +244,6 @@ func TestConvertToUppConcordance(t *testing.T) {
 		Authority:   "ManagedLocation",
 		ConcordedIds: []ConcordedID{
 			{
-				Authority:      ConcordanceAuthorityTme,
-				AuthorityValue: "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w=",
-				UUID:           "3f494231-9dc6-3181-8baa-dc9d1cad730f",
-			}, {
 				Authority:      ConcordanceAuthorityDbpedia,
 				AuthorityValue: "http://dbpedia.org/resource/Essex",
 				UUID:           "9567fbd6-f6f3-34f4-9b31-53856d5428a3",
@@ -224,6 +251,10 @@ func TestConvertToUppConcordance(t *testing.T) {
 				Authority:      ConcordanceAuthorityGeonames,
 				AuthorityValue: "http://sws.geonames.org/2649889/",
 				UUID:           "ed78ef90-a160-30d0-8a3b-472a966c5664",
+			}, {
+				Authority:      ConcordanceAuthorityTme,
+				AuthorityValue: "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w=",
+				UUID:           "3f494231-9dc6-3181-8baa-dc9d1cad730f",
 			}, {
 				Authority:      ConcordanceAuthorityWikidata,
 				AuthorityValue: "http://www.wikidata.org/entity/Q23240",
@@ -236,16 +267,16 @@ func TestConvertToUppConcordance(t *testing.T) {
 		ConceptUUID: testUUID,
 		Authority:   "Smartlogic",
 		ConcordedIds: []ConcordedID{
-			{
-				Authority:      ConcordanceAuthorityTme,
-				AuthorityValue: "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w=",
-				UUID:           "3f494231-9dc6-3181-8baa-dc9d1cad730f",
-			},
 			{
 				Authority:      ConcordanceAuthorityGeonames,
 				AuthorityValue: "http://sws.geonames.org/2649889/",
 				UUID:           "ed78ef90-a160-30d0-8a3b-472a966c5664",
 			},
+			{
+				Authority:      ConcordanceAuthorityTme,
+				AuthorityValue: "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w=",
+				UUID:           "3f494231-9dc6-3181-8baa-dc9d1cad730f",
+			},
 			{
 				Authority:      ConcordanceAuthorityWikidata,
 				AuthorityValue: "http://www.wikidata.org/entity/Q23240",
@@ -258,16 +289,16 @@ func TestConvertToUppConcordance(t *testing.T) {
 		ConceptUUID: testUUID,
 		Authority:   "Smartlogic",
 		ConcordedIds: []ConcordedID{
-			{
-				Authority:      ConcordanceAuthorityTme,
-				AuthorityValue: "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w=",
-				UUID:           "3f494231-9dc6-3181-8baa-dc9d1cad730f",
-			},
 			{
 				Authority:      ConcordanceAuthorityGeonames,
 				AuthorityValue: "http://sws.geonames.org/2649889/",
 				UUID:           "ed78ef90-a160-30d0-8a3b-472a966c5664",
 			},
+			{
+				Authority:      ConcordanceAuthorityTme,
+				AuthorityValue: "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w=",
+				UUID:           "3f494231-9dc6-3181-8baa-dc9d1cad730f",
+			},
 			{
 				Authority:      ConcordanceAuthorityWikidata,
 				AuthorityValue: "http://www.wikidata.org/entity/Q23240",
@@ -298,13 +329,13 @@ func TestConvertToUppConcordance(t *testing.T) {
 		Authority:   "Smartlogic",
 		ConcordedIds: []ConcordedID{
 			{
-				Authority:      ConcordanceAuthorityTme,
-				AuthorityValue: "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w=",
-				UUID:           "3f494231-9dc6-3181-8baa-dc9d1cad730f",
-			}, {
 				Authority:      ConcordanceAuthorityGeonames,
 				AuthorityValue: "http://sws.geonames.org/2649889/",
 				UUID:           "ed78ef90-a160-30d0-8a3b-472a966c5664",
+			}, {
+				Authority:      ConcordanceAuthorityTme,
+				AuthorityValue: "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w=",
+				UUID:           "3f494231-9dc6-3181-8baa-dc9d1cad730f",
 			},
 			{
 				Authority:      ConcordanceAuthorityWikidata,
@@ -314,10 +345,27 @@ func TestConvertToUppConcordance(t *testing.T) {
 		},
 	}
 
+	editorialDeduplicatedConcordance := editorialConcordance
+	editorialDeduplicatedConcordance.Duplicates = []DuplicateIdentifier{{
+		Authority:      ConcordanceAuthorityWikidata,
+		AuthorityValue: "http://www.wikidata.org/entity/Q23240",
+		UUID:           "76754d1e-11f6-3d4f-8e3a-59a5b4e6bdcd",
+		Path:           "$['@graph'][0]['http://www.ft.com/ontology/wikidataIdentifier'][1]['@value']",
+	}}
+	locationsDeduplicatedConcordance := locationsConcordance
+	locationsDeduplicatedConcordance.Duplicates = []DuplicateIdentifier{{
+		Authority:      ConcordanceAuthorityDbpedia,
+		AuthorityValue: "http://dbpedia.org/resource/Essex",
+		UUID:           "9567fbd6-f6f3-34f4-9b31-53856d5428a3",
+		Path:           "$['@graph'][0]['http://www.ft.com/ontology/managedlocation/dbpediaId'][1]['@value']",
+	}}
+
 	type testStruct struct {
 		testName       string
 		pathToFile     string
 		conceptUUID    string
+		conceptType    string
+		opts           []TransformerOption
 		uppConcordance UppConcordance
 		expectedError  error
 	}
@@ -326,23 +374,30 @@ func TestConvertToUppConcordance(t *testing.T) {
 	invalidTmeListInputJSON := testStruct{testName: "invalidTmeListInputJson", pathToFile: "../resources/invalidTmeListInput.json", conceptUUID: testUUID, uppConcordance: noConcordance, expectedError: errors.New("is not a valid TME Id")}
 	invalidIDFieldJSON := testStruct{testName: "invalidIdFieldJson", pathToFile: "../resources/invalidIdValue.json", conceptUUID: "", uppConcordance: noConcordance, expectedError: errors.New("Missing/invalid @id field")}
 	missingTypesField := testStruct{testName: "missingTypesField", pathToFile: "../resources/noTypes.json", conceptUUID: testUUID, uppConcordance: noConcordance, expectedError: errors.New("bad Request: Type has not been set for concept: 20db1bd6-59f9-4404-adb5-3165a448f8b0")}
-	membershipNoConcordanceNoError := testStruct{testName: "membershipNoConcordanceNoError", pathToFile: "../resources/conceptIsMembershipNoConcordance.json", conceptUUID: testUUID, uppConcordance: emptyConcordance, expectedError: nil}
+	membershipNoConcordanceNoError := testStruct{testName: "membershipNoConcordanceNoError", conceptType: "Membership", pathToFile: "../resources/conceptIsMembershipNoConcordance.json", conceptUUID: testUUID, uppConcordance: emptyConcordance, expectedError: nil}
 	errorOnMembershipConcept := testStruct{testName: "errorOnMembershipConcept", pathToFile: "../resources/conceptIsMembership.json", conceptUUID: testUUID, uppConcordance: noConcordance, expectedError: errors.New("bad Request: Concept type Membership does not support concordance")}
 	errorOnMembershipRoleConcept := testStruct{testName: "errorOnMembershipRoleConcept", pathToFile: "../resources/conceptIsMembershipRole.json", conceptUUID: testUUID, uppConcordance: noConcordance, expectedError: errors.New("bad Request: Concept type MembershipRole does not support concordance")}
 	invalidTmeID := testStruct{testName: "invalidTmeId", pathToFile: "../resources/invalidTmeId.json", conceptUUID: testUUID, uppConcordance: noConcordance, expectedError: errors.New("is not a valid TME Id")}
 	tmeGeneratedUUIDEqualConceptUUID := testStruct{testName: "tmeGeneratedUuidEqualConceptUuid", pathToFile: "../resources/tmeGeneratedUuidEqualConceptUuid.json", conceptUUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6", uppConcordance: noConcordance, expectedError: errors.New("smartlogic uuid that is the same as the uuid generated from the TME id")}
 	errorOnDuplicateTmeIds := testStruct{testName: "errorOnDuplicateTmeIds", pathToFile: "../resources/duplicateTmeIds.json", conceptUUID: testUUID, uppConcordance: noConcordance, expectedError: errors.New("contains duplicate TME id values")}
-	handlesMultipleTmeIds := testStruct{testName: "handlesMultipleTmeIds", pathToFile: "../resources/multipleTmeIds.json", conceptUUID: testUUID, uppConcordance: multiConcordance, expectedError: nil}
-	handlesNoTmeIds := testStruct{testName: "handlesNoTmeIds", pathToFile: "../resources/noTmeIds.json", conceptUUID: testUUID, uppConcordance: emptyConcordance, expectedError: nil}
-	managedLocationIds := testStruct{testName: "managedLocationIds", pathToFile: "../resources/managedLocationIds.json", conceptUUID: testUUID, uppConcordance: locationsConcordance, expectedError: nil}
-	managedLocationDuplicateIds := testStruct{testName: "managedLocationDuplicateIds", pathToFile: "../resources/managedLocationDuplicateIds.json", conceptUUID: testUUID, uppConcordance: locationsConcordance, expectedError: nil}
-	managedLocationBlankID := testStruct{testName: "managedLocationBlankId", pathToFile: "../resources/managedLocationBlankId.json", conceptUUID: testUUID, uppConcordance: locationsConcordance, expectedError: nil}
-	managedLocationMutuallyExclusiveFields := testStruct{testName: "managedLocationMutuallyExclusiveFields", pathToFile: "../resources/managedLocationMutuallyExclusiveFields.json", conceptUUID: testUUID, uppConcordance: multiTmeFactsetConcordance, expectedError: nil}
-	editorialBlankID := testStruct{testName: "editorialBlankId", pathToFile: "../resources/editorialBlankId.json", conceptUUID: testUUID, uppConcordance: noWikidataEditorialConcordance, expectedError: nil}
-	editorialDuplicateIds := testStruct{testName: "editorialDuplicateIds", pathToFile: "../resources/editorialDuplicateIds.json", conceptUUID: testUUID, uppConcordance: editorialConcordance, expectedError: nil}
-	editorialAndManagedLocationWikidata := testStruct{testName: "editorialAndManagedLocationWikidata", pathToFile: "../resources/editorialAndManagedLocationWikidata.json", conceptUUID: testUUID, uppConcordance: editorialConcordance, expectedError: nil}
-	editorialTwoWikidataIds := testStruct{testName: "editorialTwoWikidataIds", pathToFile: "../resources/editorialTwoWikidata.json", conceptUUID: testUUID, uppConcordance: editorialConcordanceTwoWikidata, expectedError: nil}
-	editorialGeonamesID := testStruct{testName: "editorialGeonamesId", pathToFile: "../resources/editorialGeonames.json", conceptUUID: testUUID, uppConcordance: editorialGeonamesConcordance, expectedError: nil}
+	handlesMultipleTmeIds := testStruct{testName: "handlesMultipleTmeIds", conceptType: "Brand", pathToFile: "../resources/multipleTmeIds.json", conceptUUID: testUUID, uppConcordance: multiConcordance, expectedError: nil}
+	handlesNoTmeIds := testStruct{testName: "handlesNoTmeIds", conceptType: "Brand", pathToFile: "../resources/noTmeIds.json", conceptUUID: testUUID, uppConcordance: emptyConcordance, expectedError: nil}
+	managedLocationIds := testStruct{testName: "managedLocationIds", conceptType: "Location", pathToFile: "../resources/managedLocationIds.json", conceptUUID: testUUID, uppConcordance: locationsConcordance, expectedError: nil}
+	managedLocationDuplicateIds := testStruct{testName: "managedLocationDuplicateIds", conceptType: "Location", pathToFile: "../resources/managedLocationDuplicateIds.json", conceptUUID: testUUID, uppConcordance: locationsDeduplicatedConcordance, expectedError: nil}
+	dedupingRegistry, err := mergeAuthorityRegistry([]AuthorityConfig{
+		{Model: conceptModelManagedLocation, Predicate: "http://www.ft.com/ontology/managedlocation/dbpediaId", Authority: ConcordanceAuthorityDbpedia, Validation: validationDbpedia, Canonicalisation: canonicalisationDbpedia, Duplicates: string(DuplicatesDedupeWarn), SkipBlank: true, Lenient: true},
+	})
+	assert.NoError(t, err)
+	managedLocationDuplicateIdsOverridden := testStruct{testName: "managedLocationDuplicateIdsOverridden", conceptType: "Location", pathToFile: "../resources/managedLocationDuplicateIds.json", conceptUUID: testUUID, opts: []TransformerOption{WithAuthorityRegistry(dedupingRegistry), WithDuplicatePolicy(DuplicatesReject)}, uppConcordance: locationsDeduplicatedConcordance, expectedError: nil}
+	managedLocationDuplicateIdsRejected := testStruct{testName: "managedLocationDuplicateIdsRejected", pathToFile: "../resources/managedLocationDuplicateIds.json", conceptUUID: testUUID, opts: []TransformerOption{WithDuplicatePolicy(DuplicatesReject)}, uppConcordance: noConcordance, expectedError: errors.New("contains duplicate DBPedia id values")}
+	managedLocationBlankID := testStruct{testName: "managedLocationBlankId", conceptType: "Location", pathToFile: "../resources/managedLocationBlankId.json", conceptUUID: testUUID, uppConcordance: locationsConcordance, expectedError: nil}
+	managedLocationMutuallyExclusiveFields := testStruct{testName: "managedLocationMutuallyExclusiveFields", conceptType: "Location", pathToFile: "../resources/managedLocationMutuallyExclusiveFields.json", conceptUUID: testUUID, uppConcordance: multiTmeFactsetConcordance, expectedError: nil}
+	editorialBlankID := testStruct{testName: "editorialBlankId", conceptType: "Location", pathToFile: "../resources/editorialBlankId.json", conceptUUID: testUUID, uppConcordance: noWikidataEditorialConcordance, expectedError: nil}
+	editorialDuplicateIds := testStruct{testName: "editorialDuplicateIds", conceptType: "Location", pathToFile: "../resources/editorialDuplicateIds.json", conceptUUID: testUUID, uppConcordance: editorialDeduplicatedConcordance, expectedError: nil}
+	editorialDuplicateIdsRejected := testStruct{testName: "editorialDuplicateIdsRejected", pathToFile: "../resources/editorialDuplicateIds.json", conceptUUID: testUUID, opts: []TransformerOption{WithDuplicatePolicy(DuplicatesReject)}, uppConcordance: noConcordance, expectedError: errors.New("contains duplicate Wikidata id values")}
+	editorialAndManagedLocationWikidata := testStruct{testName: "editorialAndManagedLocationWikidata", conceptType: "Location", pathToFile: "../resources/editorialAndManagedLocationWikidata.json", conceptUUID: testUUID, uppConcordance: editorialConcordance, expectedError: nil}
+	editorialTwoWikidataIds := testStruct{testName: "editorialTwoWikidataIds", conceptType: "Location", pathToFile: "../resources/editorialTwoWikidata.json", conceptUUID: testUUID, uppConcordance: editorialConcordanceTwoWikidata, expectedError: nil}
+	editorialGeonamesID := testStruct{testName: "editorialGeonamesId", conceptType: "Location", pathToFile: "../resources/editorialGeonames.json", conceptUUID: testUUID, uppConcordance: editorialGeonamesConcordance, expectedError: nil}
 
 	invalidFactsetID := testStruct{
 		testName:       "invalidFactsetId",
@@ -367,6 +422,7 @@ func TestConvertToUppConcordance(t *testing.T) {
 	}
 	handlesMultipleFactsetIds := testStruct{
 		testName:       "handlesMultipleFactsetIds",
+		conceptType:    "Brand",
 		pathToFile:     "../resources/multipleFactsetIds.json",
 		conceptUUID:    testUUID,
 		uppConcordance: multiFactsetConcordance,
@@ -374,6 +430,7 @@ func TestConvertToUppConcordance(t *testing.T) {
 	}
 	handlesNoFactsetIds := testStruct{
 		testName:       "handlesNoFactsetIds",
+		conceptType:    "Brand",
 		pathToFile:     "../resources/noFactsetIds.json",
 		conceptUUID:    testUUID,
 		uppConcordance: emptyConcordance,
@@ -400,22 +457,34 @@ func TestConvertToUppConcordance(t *testing.T) {
 		noErrorOnNotAllowedConceptType,
 		managedLocationIds,
 		managedLocationDuplicateIds,
+		managedLocationDuplicateIdsRejected,
+		managedLocationDuplicateIdsOverridden,
 		managedLocationBlankID,
 		managedLocationMutuallyExclusiveFields,
 		editorialBlankID,
 		editorialDuplicateIds,
+		editorialDuplicateIdsRejected,
 		editorialAndManagedLocationWikidata,
 		editorialTwoWikidataIds,
 		editorialGeonamesID,
 	}
 
 	for _, scenario := range testScenarios {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), scenario.opts...)
 		var smartLogicConcept = ConceptData{}
 		decoder := json.NewDecoder(bytes.NewBufferString(readFile(t, scenario.pathToFile)))
 		err := decoder.Decode(&smartLogicConcept)
-		_, uuid, uppConcordance, err := convertToUppConcordance(smartLogicConcept, "transaction_id", createLogger())
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		uuid, uppConcordance := "", UppConcordance{}
+		results, err := transformer.convertToUppConcordances(smartLogicConcept, "transaction_id")
+		if err == nil {
+			assert.Len(t, results, 1, "Scenario: "+scenario.testName+" failed")
+			uuid, uppConcordance, err = results[0].conceptUUID, results[0].uppConcordance, results[0].err
+		}
 		assert.Equal(t, scenario.conceptUUID, uuid, "Scenario: "+scenario.testName+" failed")
-		assert.Equal(t, scenario.uppConcordance, uppConcordance, "Scenario: "+scenario.testName+" failed. Json output does not match")
+		expectedConcordance := scenario.uppConcordance
+		expectedConcordance.ConceptType = scenario.conceptType
+		assert.Equal(t, expectedConcordance, uppConcordance, "Scenario: "+scenario.testName+" failed. Json output does not match")
 		if scenario.expectedError != nil {
 			assert.Error(t, err, "Scenario: "+scenario.testName+"should have returned error")
 			assert.Contains(t, err.Error(), scenario.expectedError.Error(), "Scenario: "+scenario.testName+" returned unexpected output")
@@ -436,23 +505,33 @@ func TestTransformerService_handleConcordanceEvent(t *testing.T) {
 	defaultTransformer := NewTransformerService(TOPIC, WriterAddress, &mockClient, createLogger())
 
 	type testStruct struct {
-		scenarioName  string
-		payload       string
-		expectedError error
+		scenarioName   string
+		payload        string
+		expectedStatus status
+		expectedError  error
 	}
 
 	invalidJSONLd := `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0"}, {"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0"}]}`
 	validJSONLdNoConcordance := `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/Brand"]}]}`
 	validJSONLdWithConcordance := `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}]}]}`
 
-	failOnInvalidKafkaMessagePayload := testStruct{scenarioName: "failOnInvalidKafkaMessagePayload", payload: "", expectedError: errors.New("EOF")}
-	failOnInvalidJSONLdInPayload := testStruct{scenarioName: "failOnInvalidJsonLdInPayload", payload: invalidJSONLd, expectedError: errors.New("invalid Request Json: More than 1 concept in smartlogic concept payload which is currently not supported")}
-	failOnWritePayloadToWriter := testStruct{scenarioName: "failOnWritePayloadToWriter", payload: validJSONLdNoConcordance, expectedError: errors.New("Internal Error: Delete request to writer returned unexpected status: 200")}
-	successfulRequest := testStruct{scenarioName: "successfulRequest", payload: validJSONLdWithConcordance, expectedError: nil}
+	failOnInvalidKafkaMessagePayload := testStruct{scenarioName: "failOnInvalidKafkaMessagePayload", payload: "", expectedStatus: SyntacticallyIncorrect, expectedError: errors.New("EOF")}
+	typeNotSet := errors.New("bad Request: Type has not been set for concept: 20db1bd6-59f9-4404-adb5-3165a448f8b0)")
+	failOnInvalidJSONLdInPayload := testStruct{scenarioName: "failOnInvalidJsonLdInPayload", payload: invalidJSONLd, expectedStatus: SyntacticallyIncorrect, expectedError: errors.Join(
+		fmt.Errorf("concept 20db1bd6-59f9-4404-adb5-3165a448f8b0: %w", typeNotSet),
+		fmt.Errorf("concept 20db1bd6-59f9-4404-adb5-3165a448f8b0: %w", typeNotSet),
+	)}
+	failOnWritePayloadToWriter := testStruct{scenarioName: "failOnWritePayloadToWriter", payload: validJSONLdNoConcordance, expectedStatus: InternalError, expectedError: errors.New("Internal Error: Delete request to writer returned unexpected status: 200")}
+	successfulRequest := testStruct{scenarioName: "successfulRequest", payload: validJSONLdWithConcordance, expectedStatus: ValidConcept, expectedError: nil}
 
 	scenarios := []testStruct{failOnInvalidKafkaMessagePayload, failOnInvalidJSONLdInPayload, failOnWritePayloadToWriter, successfulRequest}
 	for _, scenario := range scenarios {
-		err := defaultTransformer.handleConcordanceEvent(scenario.payload, "test-tid")
-		assert.Equal(t, scenario.expectedError, err, "Scenario "+scenario.scenarioName+" failed with unexpected error")
+		updateStatus, err := defaultTransformer.handleConcordanceEvent(scenario.payload, "test-tid", false)
+		assert.Equal(t, scenario.expectedStatus, updateStatus, "Scenario "+scenario.scenarioName+" failed with unexpected status")
+		if scenario.expectedError != nil {
+			assert.EqualError(t, err, scenario.expectedError.Error(), "Scenario "+scenario.scenarioName+" failed with unexpected error")
+		} else {
+			assert.NoError(t, err, "Scenario "+scenario.scenarioName+" failed with unexpected error")
+		}
 	}
 }
@@ -0,0 +1,284 @@
+package smartlogic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/IBM/sarama"
+)
+
+const (
+	messageTypeConcordanceUpdate = "concordance-update"
+	messageTypeConcordanceDelete = "concordance-delete"
+)
+
+// ConcordanceSink receives the concordance records produced by the transformer.
+type ConcordanceSink interface {
+	Name() string
+	Write(uuid string, uppConcordance UppConcordance, tid string) (sinkResponse, error)
+	Delete(uuid string, tid string) (sinkResponse, error)
+}
+
+// sinkResponse describes the outcome of sending a concordance record to a sink.
+type sinkResponse struct {
+	status     status
+	statusCode int
+	retryAfter time.Duration
+}
+
+// concordanceTombstone is published by the Kafka and file sinks when the concordances of a concept are deleted. It is
+// a record of its own rather than a Kafka tombstone with no value, as every message of the topic is an FT message.
+type concordanceTombstone struct {
+	ConceptUUID string `json:"uuid"`
+	Deleted     bool   `json:"deleted"`
+}
+
+// httpSink sends concordance records to the concordances-rw-neo4j.
+type httpSink struct {
+	writerAddress string
+	httpClient    httpClient
+	log           *logger.UPPLogger
+}
+
+func NewHTTPSink(writerAddress string, httpClient httpClient, log *logger.UPPLogger) ConcordanceSink {
+	return &httpSink{
+		writerAddress: writerAddress,
+		httpClient:    httpClient,
+		log:           log,
+	}
+}
+
+func (s *httpSink) Name() string {
+	return "http"
+}
+
+func (s *httpSink) Write(uuid string, uppConcordance UppConcordance, tid string) (sinkResponse, error) {
+	reqURL := s.writerAddress + "branches/" + uuid
+	concordedJSON, err := encodeUppConcordance(uppConcordance)
+	if err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Bad Request: Could not unmarshall concordance json")
+		return sinkResponse{status: SyntacticallyIncorrect}, err
+	}
+
+	request, err := http.NewRequest("PUT", reqURL, strings.NewReader(string(concordedJSON)))
+	if err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Internal Error: Failed to create GET request to " + reqURL + " with body " + string(concordedJSON))
+		return sinkResponse{status: InternalError}, err
+	}
+	request.ContentLength = -1
+	request.Header.Set("X-Request-Id", tid)
+
+	resp, err := s.httpClient.Do(request)
+	if err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Service Unavailable: Get request to writer resulted in error")
+		return sinkResponse{status: ServiceUnavailable}, err
+	}
+
+	defer func(body io.ReadCloser) {
+		err := body.Close()
+		if err != nil {
+			s.log.WithError(err).Info("Could not close body")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != 200 && resp.StatusCode != 201 && resp.StatusCode != 304 {
+		err := errors.New("Internal Error: Get request to writer returned unexpected status: " + strconv.Itoa(resp.StatusCode))
+		s.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid, "status": resp.StatusCode}).Error(err)
+		return sinkResponse{status: InternalError, statusCode: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}, err
+	}
+
+	return sinkResponse{status: ValidConcept, statusCode: resp.StatusCode}, nil
+}
+
+func (s *httpSink) Delete(uuid string, tid string) (sinkResponse, error) {
+	reqURL := s.writerAddress + "branches/" + uuid
+	request, err := http.NewRequest("DELETE", reqURL, strings.NewReader(""))
+	if err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Internal Error: Failed to create DELETE request to " + reqURL)
+		return sinkResponse{status: InternalError}, err
+	}
+	request.ContentLength = -1
+	request.Header.Set("X-Request-Id", tid)
+
+	resp, err := s.httpClient.Do(request)
+	if err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Service Unavailable: Delete request to writer resulted in error")
+		return sinkResponse{status: ServiceUnavailable}, err
+	}
+
+	defer func(body io.ReadCloser) {
+		err := body.Close()
+		if err != nil {
+			s.log.WithError(err).Info("Could not close body")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != 204 && resp.StatusCode != 404 {
+		err := errors.New("Internal Error: Delete request to writer returned unexpected status: " + strconv.Itoa(resp.StatusCode))
+		s.log.WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid, "status": resp.StatusCode}).Error(err)
+		return sinkResponse{status: InternalError, statusCode: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}, err
+	}
+	if resp.StatusCode == 204 {
+		return sinkResponse{status: NoContent, statusCode: resp.StatusCode}, nil
+	}
+	return sinkResponse{status: NotFound, statusCode: resp.StatusCode}, nil
+}
+
+// keyedProducer publishes messages with a Kafka key.
+type keyedProducer interface {
+	SendKeyedMessage(key string, message kafka.FTMessage) error
+	ConnectivityCheck() error
+}
+
+// KafkaSinkProducer publishes the records of the Kafka sink keyed by concept UUID, so every record of a concept lands
+// on the same partition, in order. The kafka-client-go producer can't set a key, so it only serves the connectivity
+// check.
+type KafkaSinkProducer struct {
+	topic    string
+	producer sarama.SyncProducer
+	checker  *kafka.Producer
+}
+
+func NewKafkaSinkProducer(config kafka.ProducerConfig) (*KafkaSinkProducer, error) {
+	if config.Options == nil {
+		config.Options = kafka.DefaultProducerOptions()
+	}
+	checker, err := kafka.NewProducer(config)
+	if err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewSyncProducer(strings.Split(config.BrokersConnectionString, ","), config.Options)
+	if err != nil {
+		_ = checker.Close()
+		return nil, fmt.Errorf("creating keyed producer: %w", err)
+	}
+	return &KafkaSinkProducer{
+		topic:    config.Topic,
+		producer: producer,
+		checker:  checker,
+	}, nil
+}
+
+func (p *KafkaSinkProducer) SendKeyedMessage(key string, message kafka.FTMessage) error {
+	_, _, err := p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: p.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.StringEncoder(message.Build()),
+	})
+	return err
+}
+
+func (p *KafkaSinkProducer) ConnectivityCheck() error {
+	return p.checker.ConnectivityCheck()
+}
+
+func (p *KafkaSinkProducer) Close() error {
+	return errors.Join(p.producer.Close(), p.checker.Close())
+}
+
+// kafkaSink publishes concordance records, and delete markers for deleted ones, to a Kafka topic, keyed by concept
+// UUID.
+type kafkaSink struct {
+	producer keyedProducer
+	log      *logger.UPPLogger
+}
+
+func NewKafkaSink(producer keyedProducer, log *logger.UPPLogger) ConcordanceSink {
+	return &kafkaSink{
+		producer: producer,
+		log:      log,
+	}
+}
+
+func (s *kafkaSink) Name() string {
+	return "kafka"
+}
+
+func (s *kafkaSink) Write(uuid string, uppConcordance UppConcordance, tid string) (sinkResponse, error) {
+	return s.publish(uuid, uppConcordance, messageTypeConcordanceUpdate, tid, ValidConcept)
+}
+
+func (s *kafkaSink) Delete(uuid string, tid string) (sinkResponse, error) {
+	return s.publish(uuid, concordanceTombstone{ConceptUUID: uuid, Deleted: true}, messageTypeConcordanceDelete, tid, NoContent)
+}
+
+func (s *kafkaSink) publish(uuid string, record interface{}, messageType string, tid string, successStatus status) (sinkResponse, error) {
+	body, err := encodeRecord(record)
+	if err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Bad Request: Could not marshal concordance json")
+		return sinkResponse{status: SyntacticallyIncorrect}, err
+	}
+
+	headers := map[string]string{
+		transactionIDHeader: tid,
+		"Message-Timestamp": time.Now().UTC().Format(messageTimestampFormat),
+		"Message-Type":      messageType,
+		"Content-Type":      "application/json",
+	}
+	if err = s.producer.SendKeyedMessage(uuid, kafka.NewFTMessage(headers, string(body))); err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Service Unavailable: Publishing concordance record to Kafka resulted in error")
+		return sinkResponse{status: ServiceUnavailable}, err
+	}
+	return sinkResponse{status: successStatus}, nil
+}
+
+// fileSink appends concordance records, and tombstones for deleted ones, to a local newline delimited JSON file.
+type fileSink struct {
+	file *os.File
+	mu   sync.Mutex
+	log  *logger.UPPLogger
+}
+
+func NewFileSink(path string, log *logger.UPPLogger) (ConcordanceSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{
+		file: file,
+		log:  log,
+	}, nil
+}
+
+func (s *fileSink) Name() string {
+	return "file"
+}
+
+func (s *fileSink) Write(uuid string, uppConcordance UppConcordance, tid string) (sinkResponse, error) {
+	return s.append(uuid, uppConcordance, tid, ValidConcept)
+}
+
+func (s *fileSink) Delete(uuid string, tid string) (sinkResponse, error) {
+	return s.append(uuid, concordanceTombstone{ConceptUUID: uuid, Deleted: true}, tid, NoContent)
+}
+
+func (s *fileSink) append(uuid string, record interface{}, tid string, successStatus status) (sinkResponse, error) {
+	line, err := encodeRecord(record)
+	if err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Bad Request: Could not marshal concordance json")
+		return sinkResponse{status: SyntacticallyIncorrect}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = s.file.Write(append(line, '\n')); err != nil {
+		s.log.WithError(err).WithFields(map[string]interface{}{"transaction_id": tid, "UUID": uuid}).Error("Internal Error: Writing concordance record to file resulted in error")
+		return sinkResponse{status: InternalError}, err
+	}
+	return sinkResponse{status: successStatus}, nil
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
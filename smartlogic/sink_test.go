@@ -0,0 +1,126 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaSink(t *testing.T) {
+	withConcordance := UppConcordance{Authority: ConcordanceAuthoritySmartlogic, ConceptUUID: testUUID, ConcordedIds: []ConcordedID{concordedTmeID}}
+	noConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{}}
+
+	type testStruct struct {
+		testName            string
+		uppConcordance      UppConcordance
+		producerErr         error
+		expectedStatus      status
+		expectedMessageType string
+		expectedBody        string
+	}
+
+	publishesConcordance := testStruct{
+		testName:            "publishesConcordance",
+		uppConcordance:      withConcordance,
+		expectedStatus:      ValidConcept,
+		expectedMessageType: messageTypeConcordanceUpdate,
+		expectedBody:        `{"authority":"Smartlogic","uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","concordances":[{"authority":"TME","uuid":"d83a4dc1-397e-4f99-8ecf-2f1b15febb7f"}]}`,
+	}
+	publishesTombstone := testStruct{
+		testName:            "publishesTombstone",
+		uppConcordance:      noConcordance,
+		expectedStatus:      NoContent,
+		expectedMessageType: messageTypeConcordanceDelete,
+		expectedBody:        `{"uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","deleted":true}`,
+	}
+	producerFailure := testStruct{
+		testName:       "producerFailure",
+		uppConcordance: withConcordance,
+		producerErr:    errors.New("kafka is down"),
+		expectedStatus: ServiceUnavailable,
+	}
+
+	testScenarios := []testStruct{publishesConcordance, publishesTombstone, producerFailure}
+
+	for _, scenario := range testScenarios {
+		producer := &mockProducer{err: scenario.producerErr}
+		ts := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithSinks(NewKafkaSink(producer, createLogger())))
+
+		updateStatus, err := ts.makeRelevantRequest(testUUID, scenario.uppConcordance, "tid_sink")
+		assert.Equal(t, scenario.expectedStatus, updateStatus, "Scenario: "+scenario.testName+" failed")
+		if scenario.producerErr != nil {
+			assert.Equal(t, scenario.producerErr, err, "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		if assert.Len(t, producer.messages, 1, "Scenario: "+scenario.testName+" failed") {
+			assert.Equal(t, []string{testUUID}, producer.keys, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, "tid_sink", producer.messages[0].Headers[transactionIDHeader], "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, scenario.expectedMessageType, producer.messages[0].Headers["Message-Type"], "Scenario: "+scenario.testName+" failed")
+			assert.JSONEq(t, scenario.expectedBody, producer.messages[0].Body, "Scenario: "+scenario.testName+" failed")
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concordances.ndjson")
+	sink, err := NewFileSink(path, createLogger())
+	assert.NoError(t, err)
+
+	ts := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithSinks(sink))
+	withConcordance := UppConcordance{Authority: ConcordanceAuthoritySmartlogic, ConceptUUID: testUUID, ConcordedIds: []ConcordedID{concordedTmeID}}
+
+	updateStatus, err := ts.makeRelevantRequest(testUUID, withConcordance, "tid_1")
+	assert.NoError(t, err)
+	assert.Equal(t, ValidConcept, updateStatus)
+	updateStatus, err = ts.makeRelevantRequest(testUUID, UppConcordance{ConceptUUID: testUUID}, "tid_2")
+	assert.NoError(t, err)
+	assert.Equal(t, NoContent, updateStatus)
+	assert.NoError(t, sink.(*fileSink).Close())
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if assert.Len(t, lines, 2) {
+		var written UppConcordance
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &written))
+		assert.Equal(t, withConcordance, written)
+		assert.JSONEq(t, `{"uuid":"20db1bd6-59f9-4404-adb5-3165a448f8b0","deleted":true}`, lines[1])
+	}
+}
+
+func TestMultipleSinks(t *testing.T) {
+	withConcordance := UppConcordance{ConceptUUID: testUUID, ConcordedIds: []ConcordedID{concordedTmeID}}
+
+	producer := &mockProducer{}
+	writer := NewHTTPSink(WriterAddress, mockHTTPClient{statusCode: 200}, createLogger())
+	ts := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithSinks(writer, NewKafkaSink(producer, createLogger())))
+
+	updateStatus, err := ts.makeRelevantRequest(testUUID, withConcordance, "tid_multi")
+	assert.NoError(t, err)
+	assert.Equal(t, ValidConcept, updateStatus)
+	assert.Len(t, producer.messages, 1)
+	assert.True(t, ts.hasHTTPSink())
+
+	failingWriter := NewHTTPSink(WriterAddress, mockHTTPClient{statusCode: 500}, createLogger())
+	producer = &mockProducer{}
+	ts = NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithSinks(failingWriter, NewKafkaSink(producer, createLogger())))
+
+	updateStatus, err = ts.makeRelevantRequest(testUUID, withConcordance, "tid_multi")
+	assert.Error(t, err)
+	assert.Equal(t, InternalError, updateStatus)
+	assert.Empty(t, producer.messages, "sinks after a failing one should not be called")
+
+	ts = NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithSinks(NewKafkaSink(&mockProducer{}, createLogger())))
+	assert.False(t, ts.hasHTTPSink())
+
+	ts = NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithSinks())
+	updateStatus, err = ts.makeRelevantRequest(testUUID, withConcordance, "tid_multi")
+	assert.Error(t, err)
+	assert.Equal(t, InternalError, updateStatus)
+}
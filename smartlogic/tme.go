@@ -0,0 +1,148 @@
+package smartlogic
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TmeTaxonomy is a TME taxonomy identifiers may belong to, and the short form of the concept types it fits.
+// A taxonomy without concept types fits every type.
+type TmeTaxonomy struct {
+	Name         string   `json:"name"`
+	ConceptTypes []string `json:"conceptTypes,omitempty"`
+}
+
+type tmeTaxonomyFile struct {
+	Taxonomies []TmeTaxonomy `json:"taxonomies"`
+}
+
+// TmeTaxonomies holds the known TME taxonomies. When configured, TME identifiers are decoded and those of an unknown
+// taxonomy are rejected.
+type TmeTaxonomies struct {
+	taxonomies map[string]TmeTaxonomy
+}
+
+// LoadTmeTaxonomies reads the taxonomies from a JSON file holding a "taxonomies" list of TmeTaxonomy.
+func LoadTmeTaxonomies(path string) (*TmeTaxonomies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading TME taxonomies %s: %w", path, err)
+	}
+	var config tmeTaxonomyFile
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("decoding TME taxonomies %s: %w", path, err)
+	}
+	taxonomies, err := NewTmeTaxonomies(config.Taxonomies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TME taxonomies %s: %w", path, err)
+	}
+	return taxonomies, nil
+}
+
+func NewTmeTaxonomies(configs []TmeTaxonomy) (*TmeTaxonomies, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("at least one taxonomy is required")
+	}
+	taxonomies := &TmeTaxonomies{taxonomies: map[string]TmeTaxonomy{}}
+	for i, taxonomy := range configs {
+		if taxonomy.Name == "" {
+			return nil, fmt.Errorf("taxonomy %d: name is required", i)
+		}
+		if _, found := taxonomies.taxonomies[taxonomy.Name]; found {
+			return nil, fmt.Errorf("taxonomy %d: %s is already defined", i, taxonomy.Name)
+		}
+		taxonomies.taxonomies[taxonomy.Name] = taxonomy
+	}
+	return taxonomies, nil
+}
+
+// WithTmeTaxonomies makes the TransformerService decode TME identifiers and check their taxonomy.
+func WithTmeTaxonomies(taxonomies *TmeTaxonomies) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.tmeTaxonomies = taxonomies
+	}
+}
+
+// decodeTmeID splits a TME identifier into the value and the taxonomy it is made of, both base64 encoded.
+func decodeTmeID(tmeID string) (string, string, error) {
+	encodedValue, encodedTaxonomy, found := strings.Cut(tmeID, "-")
+	if !found {
+		return "", "", errors.New("it is not made of a value and a taxonomy")
+	}
+	value, err := decodeTmePart(encodedValue)
+	if err != nil {
+		return "", "", fmt.Errorf("its value %s", err)
+	}
+	taxonomy, err := decodeTmePart(encodedTaxonomy)
+	if err != nil {
+		return "", "", fmt.Errorf("its taxonomy %s", err)
+	}
+	return value, taxonomy, nil
+}
+
+func decodeTmePart(encoded string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		// TME identifiers often leave out the padding, an unpadded part is only taken for base64 when it decodes to text
+		decoded, err = base64.RawStdEncoding.DecodeString(encoded)
+		if err != nil || !isPrintableText(decoded) {
+			return "", errors.New("is not base64 encoded")
+		}
+	}
+	if !isPrintableText(decoded) {
+		return "", errors.New("is not printable text")
+	}
+	return string(decoded), nil
+}
+
+func isPrintableText(decoded []byte) bool {
+	return len(decoded) > 0 && utf8.Valid(decoded) && strings.IndexFunc(string(decoded), func(r rune) bool { return !unicode.IsPrint(r) }) < 0
+}
+
+// checkTmeTaxonomy rejects a TME identifier which can't be decoded or belongs to an unknown taxonomy, and warns
+// when its taxonomy doesn't fit the short form type of the concept, e.g. a Brand concept with a GL identifier.
+func (ts *TransformerService) checkTmeTaxonomy(tmeID string, conceptType string, conceptUUID string, tid string) error {
+	if ts.tmeTaxonomies == nil {
+		return nil
+	}
+	_, name, err := decodeTmeID(tmeID)
+	if err != nil {
+		return fmt.Errorf("Bad Request: Concordance id %s is not a valid TME Id: %w", tmeID, err)
+	}
+	taxonomy, found := ts.tmeTaxonomies.taxonomies[name]
+	if !found {
+		return fmt.Errorf("Bad Request: Concordance id %s is not a valid TME Id: unknown taxonomy %s", tmeID, name)
+	}
+	if len(taxonomy.ConceptTypes) == 0 {
+		return nil
+	}
+	for _, fittingType := range taxonomy.ConceptTypes {
+		if fittingType == conceptType {
+			return nil
+		}
+	}
+	ts.log.WithFields(map[string]interface{}{
+		"transaction_id": tid,
+		"UUID":           conceptUUID,
+		"authority":      ConcordanceAuthorityTme,
+		"value":          tmeID,
+		"taxonomy":       name,
+		"concept_type":   conceptType,
+	}).Warn(fmt.Sprintf("TME taxonomy %s is unusual for a %s concept", name, conceptType))
+	return nil
+}
+
+// tmeTaxonomy returns the taxonomy of a TME identifier, or an empty string when it can't be decoded.
+func tmeTaxonomy(tmeID string) string {
+	_, taxonomy, err := decodeTmeID(tmeID)
+	if err != nil {
+		return ""
+	}
+	return taxonomy
+}
@@ -0,0 +1,143 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	brandTmeID    = "YzhlNzZkYTctMDJiNy00NTViLTk3NmYtNmJjYTE5NDEyM2Yw-QnJhbmRz"
+	locationTmeID = "TnN0ZWluX0dMX0dCX0VOR19HX0Vzc2V4-R0w="
+	unpaddedTmeID = "TnN0ZWluX0dMX0dCX0VOR19HX0tlbnQ-R0w"
+)
+
+func TestDecodeTmeID(t *testing.T) {
+	type testStruct struct {
+		testName         string
+		tmeID            string
+		expectedValue    string
+		expectedTaxonomy string
+		expectedError    string
+	}
+
+	brand := testStruct{testName: "brand", tmeID: brandTmeID, expectedValue: "c8e76da7-02b7-455b-976f-6bca194123f0", expectedTaxonomy: "Brands"}
+	location := testStruct{testName: "location", tmeID: locationTmeID, expectedValue: "Nstein_GL_GB_ENG_G_Essex", expectedTaxonomy: "GL"}
+	unpadded := testStruct{testName: "unpadded", tmeID: unpaddedTmeID, expectedValue: "Nstein_GL_GB_ENG_G_Kent", expectedTaxonomy: "GL"}
+	taxonomyNotBase64 := testStruct{testName: "taxonomyNotBase64", tmeID: "QWJj-0123456789", expectedError: "its taxonomy is not base64 encoded"}
+	valueNotBase64 := testStruct{testName: "valueNotBase64", tmeID: "Abc-QnJhbmRz", expectedError: "its value is not base64 encoded"}
+	taxonomyNotText := testStruct{testName: "taxonomyNotText", tmeID: "QWJj-AAEC", expectedError: "its taxonomy is not printable text"}
+	missingTaxonomy := testStruct{testName: "missingTaxonomy", tmeID: "QWJj", expectedError: "it is not made of a value and a taxonomy"}
+
+	testScenarios := []testStruct{brand, location, unpadded, taxonomyNotBase64, valueNotBase64, taxonomyNotText, missingTaxonomy}
+
+	for _, scenario := range testScenarios {
+		value, taxonomy, err := decodeTmeID(scenario.tmeID)
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedValue, value, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedTaxonomy, taxonomy, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestTmeTaxonomyValidation(t *testing.T) {
+	taxonomies, err := LoadTmeTaxonomies("../resources/tmeTaxonomies.json")
+	assert.NoError(t, err)
+
+	type testStruct struct {
+		testName             string
+		taxonomies           *TmeTaxonomies
+		tmeID                string
+		expectedConcordedIDs []ConcordedID
+		expectedError        string
+	}
+
+	knownTaxonomy := testStruct{
+		testName:             "knownTaxonomy",
+		taxonomies:           taxonomies,
+		tmeID:                brandTmeID,
+		expectedConcordedIDs: []ConcordedID{{Authority: ConcordanceAuthorityTme, AuthorityValue: brandTmeID, UUID: convertToUUID(brandTmeID)}},
+	}
+	taxonomyNotFittingTypeIsAccepted := testStruct{
+		testName:             "taxonomyNotFittingTypeIsAccepted",
+		taxonomies:           taxonomies,
+		tmeID:                locationTmeID,
+		expectedConcordedIDs: []ConcordedID{{Authority: ConcordanceAuthorityTme, AuthorityValue: locationTmeID, UUID: convertToUUID(locationTmeID)}},
+	}
+	unknownTaxonomy := testStruct{
+		testName:      "unknownTaxonomy",
+		taxonomies:    taxonomies,
+		tmeID:         "QWJj-Rm9v",
+		expectedError: "Bad Request: Concordance id QWJj-Rm9v is not a valid TME Id: unknown taxonomy Foo",
+	}
+	undecodableID := testStruct{
+		testName:      "undecodableID",
+		taxonomies:    taxonomies,
+		tmeID:         "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789",
+		expectedError: "Bad Request: Concordance id AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789 is not a valid TME Id: its value is not base64 encoded",
+	}
+	taxonomiesNotConfigured := testStruct{
+		testName:             "taxonomiesNotConfigured",
+		tmeID:                "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789",
+		expectedConcordedIDs: []ConcordedID{{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"}},
+	}
+
+	testScenarios := []testStruct{knownTaxonomy, taxonomyNotFittingTypeIsAccepted, unknownTaxonomy, undecodableID, taxonomiesNotConfigured}
+
+	for _, scenario := range testScenarios {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithTmeTaxonomies(scenario.taxonomies))
+		payload := `{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/product/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "` + scenario.tmeID + `"}]}`
+		var concept Concept
+		assert.NoError(t, json.Unmarshal([]byte(payload), &concept), "Scenario: "+scenario.testName+" failed")
+		updateStatus, _, uppConcordance, err := transformer.convertToUppConcordance(concept, "tid_tme")
+		if scenario.expectedError != "" {
+			assert.EqualError(t, err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+			assert.Equal(t, ErrCodeInvalidTmeID, errorCode(err, updateStatus), "Scenario: "+scenario.testName+" failed")
+			continue
+		}
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedConcordedIDs, uppConcordance.ConcordedIds, "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestTransformHandlerShowsTmeTaxonomy(t *testing.T) {
+	r := mux.NewRouter()
+	h := NewHandler(NewTransformerService(TOPIC, WriterAddress, &mockHTTPClient{statusCode: 200}, createLogger()), mockConsumer{}, createLogger())
+	h.RegisterHandlers(r)
+
+	payload := `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/product/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "` + brandTmeID + `"}, {"@value": "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789"}]}]}`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, newRequest("POST", "/transform", payload))
+	assert.Equal(t, 200, rec.Code)
+
+	var uppConcordance UppConcordance
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &uppConcordance))
+	assert.Equal(t, []ConcordedID{
+		{Authority: ConcordanceAuthorityTme, AuthorityValue: "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789", UUID: "e9f4525a-401f-3b23-a68e-e48f314cdce6"},
+		{Authority: ConcordanceAuthorityTme, AuthorityValue: brandTmeID, UUID: convertToUUID(brandTmeID), Taxonomy: "Brands"},
+	}, uppConcordance.ConcordedIds)
+}
+
+func TestTransformHandlerShowsTaxonomyOfUnpaddedTmeID(t *testing.T) {
+	taxonomies, err := LoadTmeTaxonomies("../resources/tmeTaxonomies.json")
+	assert.NoError(t, err)
+	r := mux.NewRouter()
+	h := NewHandler(NewTransformerService(TOPIC, WriterAddress, &mockHTTPClient{statusCode: 200}, createLogger(), WithTmeTaxonomies(taxonomies)), mockConsumer{}, createLogger())
+	h.RegisterHandlers(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, newRequest("POST", "/transform", readFile(t, "../resources/unpaddedTmeId.json")))
+	assert.Equal(t, 200, rec.Code)
+
+	var uppConcordance UppConcordance
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &uppConcordance))
+	assert.Equal(t, []ConcordedID{
+		{Authority: ConcordanceAuthorityTme, AuthorityValue: unpaddedTmeID, UUID: convertToUUID(unpaddedTmeID), Taxonomy: "GL"},
+	}, uppConcordance.ConcordedIds)
+}
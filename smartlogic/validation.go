@@ -0,0 +1,64 @@
+package smartlogic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationIssue describes an identifier of a concept which could not be turned into a concordance.
+// Path is a JSONPath to the offending value in the Smartlogic payload.
+type ValidationIssue struct {
+	Code      ErrorCode `json:"code"`
+	Authority string    `json:"authority,omitempty"`
+	Value     string    `json:"value,omitempty"`
+	Reason    string    `json:"reason"`
+	Path      string    `json:"path"`
+}
+
+// ValidationError holds the issues found in the identifiers of a concept: only the first one by default, all of
+// them when the TransformerService collects validation errors.
+type ValidationError struct {
+	ConceptUUID string
+	Issues      []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Issues) == 1 {
+		return e.Issues[0].Reason
+	}
+	reasons := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		reasons = append(reasons, issue.Reason)
+	}
+	return fmt.Sprintf("%d invalid identifiers: %s", len(e.Issues), strings.Join(reasons, "; "))
+}
+
+// WithCollectValidationErrors makes the TransformerService report every invalid identifier of a concept rather
+// than stopping at the first one.
+func WithCollectValidationErrors(collect bool) TransformerOption {
+	return func(ts *TransformerService) {
+		ts.collectValidationErrors = collect
+	}
+}
+
+// validationIssues returns the issues of every ValidationError in the tree of err, so the issues of all the
+// concepts of a payload are reported. Joined errors are walked first, as errors.As would stop at the first concept.
+func validationIssues(err error) []ValidationIssue {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var issues []ValidationIssue
+		for _, e := range joined.Unwrap() {
+			issues = append(issues, validationIssues(e)...)
+		}
+		return issues
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Issues
+	}
+	return nil
+}
+
+func predicatePath(conceptIndex int, predicate string) string {
+	return fmt.Sprintf("$['@graph'][%d]['%s']", conceptIndex, strings.ReplaceAll(predicate, "'", `\'`))
+}
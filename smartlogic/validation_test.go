@@ -0,0 +1,128 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var multipleInvalidIdsIssues = []ValidationIssue{
+	{
+		Code:      ErrCodeInvalidTmeID,
+		Authority: ConcordanceAuthorityTme,
+		Value:     "AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789",
+		Reason:    "Bad Request: Concordance id AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789 is not a valid TME Id",
+		Path:      "$['@graph'][0]['http://www.ft.com/ontology/TMEIdentifier'][1]['@value']",
+	},
+	{
+		Code:      ErrCodeInvalidTmeID,
+		Authority: ConcordanceAuthorityTme,
+		Value:     "ZyXwVuTsRqPoNmLkJiHgFeDcBa",
+		Reason:    "Bad Request: Concordance id ZyXwVuTsRqPoNmLkJiHgFeDcBa is not a valid TME Id",
+		Path:      "$['@graph'][0]['http://www.ft.com/ontology/TMEIdentifier'][2]['@value']",
+	},
+	{
+		Code:      ErrCodeDuplicateIdentifier,
+		Authority: ConcordanceAuthorityTme,
+		Value:     "AbCdEfgHiJkLMnOpQrStUvWxYz-0123456789",
+		Reason:    "bad Request: Payload from smartlogic contains duplicate TME id values",
+		Path:      "$['@graph'][0]['http://www.ft.com/ontology/TMEIdentifier'][3]['@value']",
+	},
+	{
+		Code:      ErrCodeInvalidFactsetID,
+		Authority: ConcordanceAuthorityFactset,
+		Value:     "000D63-A",
+		Reason:    "Bad Request: Concordance id 000D63-A is not a valid FACTSET Id: unknown suffix -A",
+		Path:      "$['@graph'][0]['http://www.ft.com/ontology/factsetIdentifier'][0]['@value']",
+	},
+}
+
+func TestCollectValidationErrors(t *testing.T) {
+	type testStruct struct {
+		testName       string
+		collect        bool
+		expectedIssues []ValidationIssue
+		expectedError  string
+	}
+
+	failFast := testStruct{
+		testName:       "failFast",
+		collect:        false,
+		expectedIssues: multipleInvalidIdsIssues[:1],
+		expectedError:  "Bad Request: Concordance id AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789 is not a valid TME Id",
+	}
+	collectAll := testStruct{
+		testName:       "collectAll",
+		collect:        true,
+		expectedIssues: multipleInvalidIdsIssues,
+		expectedError: "4 invalid identifiers: Bad Request: Concordance id AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789 is not a valid TME Id; " +
+			"Bad Request: Concordance id ZyXwVuTsRqPoNmLkJiHgFeDcBa is not a valid TME Id; " +
+			"bad Request: Payload from smartlogic contains duplicate TME id values; " +
+			"Bad Request: Concordance id 000D63-A is not a valid FACTSET Id: unknown suffix -A",
+	}
+
+	for _, scenario := range []testStruct{failFast, collectAll} {
+		transformer := NewTransformerService(TOPIC, WriterAddress, nil, createLogger(), WithCollectValidationErrors(scenario.collect))
+		var concepts ConceptData
+		assert.NoError(t, json.Unmarshal([]byte(readFile(t, "../resources/multipleInvalidIds.json")), &concepts), "Scenario: "+scenario.testName+" failed")
+
+		results, err := transformer.convertToUppConcordances(concepts, "tid_validation")
+		assert.NoError(t, err, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, SyntacticallyIncorrect, results[0].status, "Scenario: "+scenario.testName+" failed")
+		assert.EqualError(t, results[0].err, scenario.expectedError, "Scenario: "+scenario.testName+" failed")
+		assert.Equal(t, scenario.expectedIssues, validationIssues(results[0].err), "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestValidationErrorsAreReported(t *testing.T) {
+	producer := &mockProducer{}
+	transformer := NewTransformerService(TOPIC, WriterAddress, mockHTTPClient{statusCode: 200}, createLogger(), WithCollectValidationErrors(true))
+	h := NewHandler(transformer, mockConsumer{}, createLogger(), WithDeadLetterProducer(producer))
+	r := mux.NewRouter()
+	h.RegisterHandlers(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, newRequest("POST", "/transform", readFile(t, "../resources/multipleInvalidIds.json")))
+	assert.Equal(t, 400, rec.Code)
+	var response struct {
+		Errors []ValidationIssue `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, multipleInvalidIdsIssues, response.Errors)
+
+	h.ProcessKafkaMessage(kafka.NewFTMessage(map[string]string{transactionIDHeader: "tid_validation"}, readFile(t, "../resources/multipleInvalidIds.json")))
+	if assert.Len(t, producer.messages, 1) {
+		var message deadLetterMessage
+		assert.NoError(t, json.Unmarshal([]byte(producer.messages[0].Body), &message))
+		assert.Equal(t, multipleInvalidIdsIssues, message.Errors)
+	}
+}
+
+func TestValidationIssuesOfEveryConcept(t *testing.T) {
+	payload := `{"@graph": [
+		{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0", "@type": ["http://www.ft.com/ontology/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789"}]},
+		{"@id": "http://www.ft.com/thing/95f00e25-9a5f-45ec-8ad8-5607d021c74b", "@type": ["http://www.ft.com/ontology/Brand"], "http://www.ft.com/ontology/TMEIdentifier": [{"@value": "ZyXwVuTsRqPoNmLkJiHgFeDcBa"}, {"@value": "AbCdEf-gHiJkLMnOpQ-rStUvXyZ-0123456789"}]}
+	]}`
+	producer := &mockProducer{}
+	transformer := NewTransformerService(TOPIC, WriterAddress, mockHTTPClient{statusCode: 200}, createLogger(), WithCollectValidationErrors(true))
+	h := NewHandler(transformer, mockConsumer{}, createLogger(), WithDeadLetterProducer(producer))
+
+	h.ProcessKafkaMessage(kafka.NewFTMessage(map[string]string{transactionIDHeader: "tid_validation"}, payload))
+	if assert.Len(t, producer.messages, 1) {
+		var message deadLetterMessage
+		assert.NoError(t, json.Unmarshal([]byte(producer.messages[0].Body), &message))
+		var paths []string
+		for _, issue := range message.Errors {
+			paths = append(paths, issue.Path)
+		}
+		assert.Equal(t, []string{
+			"$['@graph'][0]['http://www.ft.com/ontology/TMEIdentifier'][0]['@value']",
+			"$['@graph'][1]['http://www.ft.com/ontology/TMEIdentifier'][0]['@value']",
+			"$['@graph'][1]['http://www.ft.com/ontology/TMEIdentifier'][1]['@value']",
+		}, paths, "the issues of every failing concept should be reported")
+	}
+}
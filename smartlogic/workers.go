@@ -0,0 +1,163 @@
+package smartlogic
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+const workerQueueSize = 16
+
+// conceptWorkerPool processes Kafka messages concurrently while keeping the messages of a single concept in order:
+// every concept is always routed to the same worker, which handles its queue sequentially. A message holding concepts
+// owned by several workers is queued on each of them, and processed once all of them have reached it, so it keeps its
+// place in the order of every one of its concepts.
+type conceptWorkerPool struct {
+	queues     []chan workItem
+	process    func(msg kafka.FTMessage)
+	pending    int64
+	closed     bool
+	mu         sync.RWMutex
+	dispatchMu sync.Mutex
+	wg         sync.WaitGroup
+}
+
+type workItem struct {
+	msg     kafka.FTMessage
+	barrier *workBarrier
+	done    chan struct{}
+}
+
+// workBarrier holds back the workers a message is queued on until the last of them reaches it, which then processes
+// the message and releases the others.
+type workBarrier struct {
+	waiting int32
+}
+
+func newConceptWorkerPool(concurrency int, process func(msg kafka.FTMessage)) *conceptWorkerPool {
+	p := &conceptWorkerPool{
+		queues:  make([]chan workItem, concurrency),
+		process: process,
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan workItem, workerQueueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *conceptWorkerPool) work(queue chan workItem) {
+	defer p.wg.Done()
+	for item := range queue {
+		if item.barrier != nil && atomic.AddInt32(&item.barrier.waiting, -1) > 0 {
+			<-item.done
+			continue
+		}
+		p.process(item.msg)
+		atomic.AddInt64(&p.pending, -1)
+		close(item.done)
+	}
+}
+
+// dispatch processes the message on the workers owning its concepts and only returns once it has been processed, as
+// the Kafka consumer commits the offset of a message as soon as its handler returns.
+func (p *conceptWorkerPool) dispatch(conceptKeys []string, msg kafka.FTMessage) {
+	<-p.enqueue(conceptKeys, msg)
+}
+
+// enqueue queues the message on the workers owning its concepts, blocking while one of their queues is full, and
+// returns a channel closed once the message has been processed.
+func (p *conceptWorkerPool) enqueue(conceptKeys []string, msg kafka.FTMessage) <-chan struct{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	done := make(chan struct{})
+	if p.closed {
+		p.process(msg)
+		close(done)
+		return done
+	}
+
+	workers := p.workersOf(conceptKeys)
+	atomic.AddInt64(&p.pending, 1)
+	if len(workers) == 1 {
+		p.queues[workers[0]] <- workItem{msg: msg, done: done}
+		return done
+	}
+	// messages spanning several workers are queued one at a time, so every worker meets them in the same order
+	p.dispatchMu.Lock()
+	defer p.dispatchMu.Unlock()
+	barrier := &workBarrier{waiting: int32(len(workers))}
+	for _, worker := range workers {
+		p.queues[worker] <- workItem{msg: msg, barrier: barrier, done: done}
+	}
+	return done
+}
+
+// workersOf returns the workers owning the concepts, in ascending order and each once.
+func (p *conceptWorkerPool) workersOf(conceptKeys []string) []int {
+	if len(conceptKeys) == 0 {
+		conceptKeys = []string{""}
+	}
+	owners := make([]bool, len(p.queues))
+	for _, conceptKey := range conceptKeys {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(conceptKey))
+		owners[h.Sum32()%uint32(len(p.queues))] = true
+	}
+	var workers []int
+	for worker, owner := range owners {
+		if owner {
+			workers = append(workers, worker)
+		}
+	}
+	return workers
+}
+
+// close stops accepting messages and waits for the queued ones to be processed.
+func (p *conceptWorkerPool) close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *conceptWorkerPool) concurrency() int {
+	return len(p.queues)
+}
+
+func (p *conceptWorkerPool) capacity() int {
+	return len(p.queues) * workerQueueSize
+}
+
+func (p *conceptWorkerPool) pendingMessages() int {
+	return int(atomic.LoadInt64(&p.pending))
+}
+
+// conceptKeys returns the @id of every concept in the payload, used to route a message to its workers.
+// Payloads which can't be decoded have no key; they fail fast in the transformer anyway.
+func conceptKeys(msgBody string) []string {
+	payload := struct {
+		Concepts []struct {
+			ID string `json:"@id"`
+		} `json:"@graph"`
+	}{}
+	if err := json.Unmarshal([]byte(msgBody), &payload); err != nil {
+		return nil
+	}
+	var keys []string
+	for _, concept := range payload.Concepts {
+		keys = append(keys, concept.ID)
+	}
+	return keys
+}
@@ -0,0 +1,174 @@
+package smartlogic
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConceptWorkerPoolKeepsOrderPerConcept(t *testing.T) {
+	var mu sync.Mutex
+	processed := map[string][]string{}
+
+	pool := newConceptWorkerPool(4, func(msg kafka.FTMessage) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		key := msg.Headers["Concept"]
+		processed[key] = append(processed[key], msg.Headers["X-Request-Id"])
+	})
+
+	expected := map[string][]string{}
+	for i := 0; i < 20; i++ {
+		for _, concept := range []string{"concept-a", "concept-b", "concept-c"} {
+			tid := fmt.Sprintf("tid_%s_%d", concept, i)
+			expected[concept] = append(expected[concept], tid)
+			pool.enqueue([]string{concept}, kafka.NewFTMessage(map[string]string{"Concept": concept, "X-Request-Id": tid}, ""))
+		}
+	}
+	pool.close()
+
+	assert.Equal(t, expected, processed)
+	assert.Equal(t, 0, pool.pendingMessages())
+}
+
+func TestConceptWorkerPoolProcessesConceptsInParallel(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 2)
+
+	pool := newConceptWorkerPool(2, func(msg kafka.FTMessage) {
+		started <- msg.Headers["Concept"]
+		<-release
+	})
+
+	// both keys are known to hash to different workers when there are two of them
+	pool.enqueue([]string{"http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0"}, kafka.NewFTMessage(map[string]string{"Concept": "first"}, ""))
+	pool.enqueue([]string{"http://www.ft.com/thing/2d3e16e0-61cb-4322-8aff-3b01c59f4daa"}, kafka.NewFTMessage(map[string]string{"Concept": "second"}, ""))
+
+	var concepts []string
+	for i := 0; i < 2; i++ {
+		select {
+		case concept := <-started:
+			concepts = append(concepts, concept)
+		case <-time.After(time.Second):
+			t.Fatal("concepts were not processed in parallel")
+		}
+	}
+	assert.ElementsMatch(t, []string{"first", "second"}, concepts)
+	assert.Equal(t, 2, pool.pendingMessages())
+
+	close(release)
+	pool.close()
+	assert.Equal(t, 0, pool.pendingMessages())
+}
+
+func TestConceptWorkerPoolKeepsOrderOfConceptsSharedByMessages(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+
+	pool := newConceptWorkerPool(2, func(msg kafka.FTMessage) {
+		tid := msg.Headers["X-Request-Id"]
+		mu.Lock()
+		events = append(events, "start "+tid)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		events = append(events, "end "+tid)
+		mu.Unlock()
+	})
+
+	// the first concepts of the messages are known to hash to different workers when there are two of them
+	first := `{"@graph": [{"@id": "http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0"}, {"@id": "http://www.ft.com/thing/4b7f7e4e-7b0c-4d5e-9e52-8a1d9a5e3f10"}]}`
+	second := `{"@graph": [{"@id": "http://www.ft.com/thing/2d3e16e0-61cb-4322-8aff-3b01c59f4daa"}, {"@id": "http://www.ft.com/thing/4b7f7e4e-7b0c-4d5e-9e52-8a1d9a5e3f10"}]}`
+	pool.enqueue(conceptKeys(first), kafka.NewFTMessage(map[string]string{"X-Request-Id": "tid_first"}, first))
+	pool.enqueue(conceptKeys(second), kafka.NewFTMessage(map[string]string{"X-Request-Id": "tid_second"}, second))
+	pool.close()
+
+	assert.Equal(t, []string{"start tid_first", "end tid_first", "start tid_second", "end tid_second"}, events)
+	assert.Equal(t, 0, pool.pendingMessages())
+}
+
+func TestConceptKeys(t *testing.T) {
+	type testStruct struct {
+		testName     string
+		body         string
+		expectedKeys []string
+	}
+
+	validPayload := testStruct{testName: "validPayload", body: readFile(t, "../resources/multipleTmeIds.json"), expectedKeys: []string{"http://www.ft.com/thing/20db1bd6-59f9-4404-adb5-3165a448f8b0"}}
+	managedLocationPayload := testStruct{testName: "managedLocationPayload", body: readFile(t, "../resources/managedLocationIds.json"), expectedKeys: []string{"http://www.ft.com/ontology/managedlocation/20db1bd6-59f9-4404-adb5-3165a448f8b0"}}
+	multiConceptPayload := testStruct{testName: "multiConceptPayload", body: `{"@graph": [{"@id": "http://www.ft.com/thing/a"}, {"@id": "http://www.ft.com/thing/b"}]}`, expectedKeys: []string{"http://www.ft.com/thing/a", "http://www.ft.com/thing/b"}}
+	emptyGraph := testStruct{testName: "emptyGraph", body: `{"@graph": []}`, expectedKeys: nil}
+	invalidJSON := testStruct{testName: "invalidJson", body: "not json", expectedKeys: nil}
+
+	testScenarios := []testStruct{validPayload, managedLocationPayload, multiConceptPayload, emptyGraph, invalidJSON}
+
+	for _, scenario := range testScenarios {
+		assert.Equal(t, scenario.expectedKeys, conceptKeys(scenario.body), "Scenario: "+scenario.testName+" failed")
+	}
+}
+
+func TestProcessKafkaMessageWithConcurrency(t *testing.T) {
+	client := &countingHTTPClient{statusCode: 200}
+	transformer := NewTransformerService(TOPIC, WriterAddress, client, createLogger())
+	h := NewHandler(transformer, mockConsumer{}, createLogger(), WithConcurrency(3))
+
+	msg, _ := h.checkKafkaWorkers()
+	assert.Equal(t, "Kafka messages are processed by 3 workers, 0 messages pending", msg)
+
+	for i := 0; i < 10; i++ {
+		h.ProcessKafkaMessage(kafka.NewFTMessage(map[string]string{}, readFile(t, "../resources/multipleTmeIds.json")))
+		assert.Equal(t, i+1, client.count(), "the message should be processed before its offset is committed")
+	}
+	h.Close()
+
+	assert.Equal(t, 10, client.count())
+}
+
+func TestConceptWorkerPoolDispatchWaitsForProcessing(t *testing.T) {
+	release := make(chan struct{})
+	pool := newConceptWorkerPool(2, func(kafka.FTMessage) { <-release })
+	defer pool.close()
+
+	dispatched := make(chan struct{})
+	go func() {
+		pool.dispatch([]string{"concept-a"}, newTestMessage("tid_a"))
+		close(dispatched)
+	}()
+
+	select {
+	case <-dispatched:
+		t.Fatal("dispatch returned before the message was processed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-dispatched:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch did not return once the message was processed")
+	}
+}
+
+type countingHTTPClient struct {
+	statusCode int
+	mu         sync.Mutex
+	calls      int
+}
+
+func (c *countingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	return mockHTTPClient{statusCode: c.statusCode}.Do(req)
+}
+
+func (c *countingHTTPClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}